@@ -0,0 +1,297 @@
+package camt
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+// Namespace of the ISO 20022 camt.053.001.02 (Bank To Customer Statement) documents written by Writer
+const Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+
+const (
+	dateFormat     = "2006-01-02"
+	dateTimeFormat = "2006-01-02T15:04:05"
+
+	// max35Text is the longest identifier the schema accepts
+	max35Text = 35
+	// max140Text is the longest free text the schema accepts
+	max140Text = 140
+)
+
+// ibanPattern is the pattern of the IBAN type of the schema
+var ibanPattern = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[a-zA-Z0-9]{1,30}$`)
+
+// bankTransactionCodes maps the transaction types to their ISO bank transaction code (domain, family, sub family)
+var bankTransactionCodes = map[string][3]string{
+	transactions.TypeFee:     {"ACMT", "MDOP", "CHRG"},
+	transactions.TypePayment: {"PMNT", "ICDT", "ESCT"},
+	transactions.TypeCredit:  {"PMNT", "RCDT", "ESCT"},
+	transactions.TypeRefund:  {"PMNT", "RCDT", "RRTN"},
+}
+
+type amount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type date struct {
+	Date string `xml:"Dt"`
+}
+
+type balance struct {
+	Code      string `xml:"Tp>CdOrPrtry>Cd"`
+	Amount    amount `xml:"Amt"`
+	Indicator string `xml:"CdtDbtInd"`
+	Date      date   `xml:"Dt"`
+}
+
+type account struct {
+	IBAN     string `xml:"Id>IBAN,omitempty"`
+	Other    string `xml:"Id>Othr>Id,omitempty"`
+	Currency string `xml:"Ccy,omitempty"`
+	Servicer *agent `xml:"Svcr,omitempty"`
+}
+
+type agent struct {
+	BIC  string `xml:"FinInstnId>BIC,omitempty"`
+	Name string `xml:"FinInstnId>Nm,omitempty"`
+}
+
+type party struct {
+	Name string `xml:"Nm"`
+}
+
+type partyAccount struct {
+	IBAN string `xml:"Id>IBAN"`
+}
+
+type relatedParties struct {
+	Debtor          *party        `xml:"Dbtr,omitempty"`
+	DebtorAccount   *partyAccount `xml:"DbtrAcct,omitempty"`
+	Creditor        *party        `xml:"Cdtr,omitempty"`
+	CreditorAccount *partyAccount `xml:"CdtrAcct,omitempty"`
+}
+
+type transactionDetails struct {
+	References     *references     `xml:"Refs,omitempty"`
+	RelatedParties *relatedParties `xml:"RltdPties,omitempty"`
+	Remittance     *remittance     `xml:"RmtInf,omitempty"`
+}
+
+type references struct {
+	EndToEndID string `xml:"EndToEndId"`
+}
+
+type remittance struct {
+	Unstructured string `xml:"Ustrd"`
+}
+
+type bankTransactionCode struct {
+	Domain      *domain      `xml:"Domn,omitempty"`
+	Proprietary *proprietary `xml:"Prtry,omitempty"`
+}
+
+type domain struct {
+	Code      string `xml:"Cd"`
+	Family    string `xml:"Fmly>Cd"`
+	SubFamily string `xml:"Fmly>SubFmlyCd"`
+}
+
+type proprietary struct {
+	Code string `xml:"Cd"`
+}
+
+type entry struct {
+	XMLName     xml.Name            `xml:"Ntry"`
+	Reference   string              `xml:"NtryRef"`
+	Amount      amount              `xml:"Amt"`
+	Indicator   string              `xml:"CdtDbtInd"`
+	Status      string              `xml:"Sts"`
+	BookingDate date                `xml:"BookgDt"`
+	ValueDate   *date               `xml:"ValDt,omitempty"`
+	ServicerRef string              `xml:"AcctSvcrRef"`
+	Code        bankTransactionCode `xml:"BkTxCd"`
+	Details     transactionDetails  `xml:"NtryDtls>TxDtls"`
+}
+
+// Writer writes a camt.053 statement: the header and balances, then one entry per booked transaction (see
+// transactions.IsBooked). Documents which do not move money (invoices, receipts...) and cancelled transactions
+// are skipped. The entries are written as they come, the transactions must be read by creation date for them to
+// be listed by booking date. It implements transactions.ExportWriter.
+type Writer struct {
+	encoder       *xml.Encoder
+	statement     *transactions.Statement
+	headerWritten bool
+	err           error
+}
+
+// NewWriter returns a Writer of the statement
+func NewWriter(w io.Writer, statement *transactions.Statement) *Writer {
+	return &Writer{encoder: xml.NewEncoder(w), statement: statement}
+}
+
+func truncate(value string, maxLength int) string {
+	runes := []rune(value)
+	if len(runes) > maxLength {
+		return string(runes[:maxLength])
+	}
+	return value
+}
+
+// toAmount splits a signed amount into the positive amount and the credit/debit indicator the schema expects
+func toAmount(value float64, currency string) (amount, string) {
+	indicator := "CRDT"
+	if value < 0 {
+		indicator = "DBIT"
+	}
+	return amount{Currency: currency, Value: strconv.FormatFloat(math.Abs(value), 'f', 2, 64)}, indicator
+}
+
+func (w *Writer) start(name string, attrs ...xml.Attr) {
+	w.token(xml.StartElement{Name: xml.Name{Local: name}, Attr: attrs})
+}
+
+func (w *Writer) end(name string) {
+	w.token(xml.EndElement{Name: xml.Name{Local: name}})
+}
+
+func (w *Writer) token(token xml.Token) {
+	if w.err == nil {
+		w.err = w.encoder.EncodeToken(token)
+	}
+}
+
+func (w *Writer) element(value interface{}, name string) {
+	if w.err == nil {
+		w.err = w.encoder.EncodeElement(value, xml.StartElement{Name: xml.Name{Local: name}})
+	}
+}
+
+func (w *Writer) balance(code string, value float64, at time.Time) {
+	amt, indicator := toAmount(value, w.statement.Currency)
+	w.element(balance{Code: code, Amount: amt, Indicator: indicator, Date: date{Date: at.Format(dateFormat)}}, "Bal")
+}
+
+func (w *Writer) writeHeader() {
+	if w.headerWritten {
+		return
+	}
+	w.headerWritten = true
+
+	s := w.statement
+	now := time.Now().UTC()
+	// the statement covers [From, To), the last booking day is the day before To
+	lastDay := s.To.Add(-time.Nanosecond)
+	id := truncate(fmt.Sprintf("%s-%s-%s", s.From.Format("20060102"), lastDay.Format("20060102"), s.AccountID), max35Text)
+
+	w.token(xml.ProcInst{Target: "xml", Inst: []byte(`version="1.0" encoding="UTF-8"`)})
+	w.start("Document", xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: Namespace})
+	w.start("BkToCstmrStmt")
+
+	w.start("GrpHdr")
+	// the account ID is truncated rather than the timestamp making the message unique
+	suffix := fmt.Sprintf("-%d", now.UnixNano())
+	w.element(truncate(s.AccountID, max35Text-len(suffix))+suffix, "MsgId")
+	w.element(now.Format(dateTimeFormat), "CreDtTm")
+	w.end("GrpHdr")
+
+	w.start("Stmt")
+	w.element(id, "Id")
+	w.element(now.Format(dateTimeFormat), "CreDtTm")
+	w.start("FrToDt")
+	w.element(s.From.Format(dateTimeFormat), "FrDtTm")
+	w.element(lastDay.Format(dateTimeFormat), "ToDtTm")
+	w.end("FrToDt")
+
+	acct := account{Other: truncate(s.AccountID, max35Text), Currency: s.Currency}
+	if s.Issuer.BIC != "" || s.Issuer.Name != "" {
+		acct.Servicer = &agent{BIC: s.Issuer.BIC, Name: truncate(s.Issuer.Name, max140Text)}
+	}
+	w.element(acct, "Acct")
+
+	w.balance("OPBD", s.OpeningBalance, s.From)
+	w.balance("CLBD", s.ClosingBalance, lastDay)
+}
+
+// Write writes an entry per booked transaction
+func (w *Writer) Write(list []*transactions.Transaction) error {
+	w.writeHeader()
+
+	for _, t := range list {
+		if !transactions.IsBooked(t) {
+			continue
+		}
+		w.element(w.toEntry(t), "Ntry")
+	}
+	if w.err == nil {
+		w.err = w.encoder.Flush()
+	}
+	return w.err
+}
+
+func (w *Writer) toEntry(t *transactions.Transaction) entry {
+	amt, indicator := toAmount(transactions.SignedAmount(t), t.Currency)
+
+	e := entry{
+		Reference:   truncate(t.ID, max35Text),
+		Amount:      amt,
+		Indicator:   indicator,
+		Status:      "BOOK",
+		BookingDate: date{Date: t.CreationDate.Format(dateFormat)},
+		ServicerRef: truncate(t.ID, max35Text),
+	}
+
+	if t.Reference != "" {
+		e.Details.References = &references{EndToEndID: truncate(t.Reference, max35Text)}
+	}
+	if t.Description != "" {
+		e.Details.Remittance = &remittance{Unstructured: truncate(t.Description, max140Text)}
+	}
+
+	if t.DueDate != nil {
+		e.ValueDate = &date{Date: t.DueDate.Format(dateFormat)}
+	}
+
+	if code, ok := bankTransactionCodes[t.Type]; ok {
+		e.Code = bankTransactionCode{Domain: &domain{Code: code[0], Family: code[1], SubFamily: code[2]}}
+	} else {
+		e.Code = bankTransactionCode{Proprietary: &proprietary{Code: t.Type}}
+	}
+
+	// the counterparty is the creditor of the money going out and the debtor of the money coming in
+	if t.Counterparty != nil && t.Counterparty.Name != "" {
+		p := &party{Name: truncate(t.Counterparty.Name, max140Text)}
+		var acct *partyAccount
+		if iban := strings.ToUpper(strings.Join(strings.Fields(t.Counterparty.IBAN), "")); ibanPattern.MatchString(iban) {
+			acct = &partyAccount{IBAN: iban}
+		}
+		if indicator == "DBIT" {
+			e.Details.RelatedParties = &relatedParties{Creditor: p, CreditorAccount: acct}
+		} else {
+			e.Details.RelatedParties = &relatedParties{Debtor: p, DebtorAccount: acct}
+		}
+	}
+
+	return e
+}
+
+// Close terminates the document, it must be called even if no transaction was written
+func (w *Writer) Close() error {
+	w.writeHeader()
+
+	w.end("Stmt")
+	w.end("BkToCstmrStmt")
+	w.end("Document")
+	if w.err == nil {
+		w.err = w.encoder.Flush()
+	}
+	return w.err
+}
@@ -0,0 +1,171 @@
+package camt
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+// schemaPath is the official camt.053.001.02 schema, published by ISO 20022 (www.iso20022.org, Bank-to-Customer
+// Cash Management, 2009 edition). It must be committed next to this file: the output is validated against it
+// with xmllint.
+const schemaPath = "testdata/camt.053.001.02.xsd"
+
+func testStatement() (*transactions.Statement, [][]*transactions.Transaction) {
+	statement := &transactions.Statement{
+		Issuer:         transactions.StatementIssuer{BIC: "AGRIFRPPXXX", Name: "Bank"},
+		AccountID:      strings.Repeat("a", 50),
+		Currency:       "EUR",
+		From:           time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:             time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		OpeningBalance: 100,
+		ClosingBalance: 138,
+	}
+
+	day := func(d int) time.Time { return time.Date(2026, 1, d, 10, 0, 0, 0, time.UTC) }
+	due := day(20)
+	// the transactions come in batches by creation date, like the endpoint streams them
+	batches := [][]*transactions.Transaction{
+		{
+			{ID: "invoice-2", Type: transactions.TypeInvoice, Status: transactions.StatusOpen, Amount: 500, Currency: "EUR", CreationDate: day(2)},
+			{ID: "credit-3", Type: transactions.TypeCredit, Status: transactions.StatusPaid, Amount: 50, Currency: "EUR", CreationDate: day(3),
+				Counterparty: &transactions.Counterparty{Name: "Client", IBAN: "not an iban"}},
+		},
+		{
+			{ID: "credit-4", Type: transactions.TypeCredit, Status: transactions.StatusCancelled, Amount: 999, Currency: "EUR", CreationDate: day(4)},
+			{ID: "refund-9", Type: transactions.TypeRefund, Status: transactions.StatusRefunded, Amount: 0.5, Currency: "EUR", CreationDate: day(9)},
+			{ID: "payment-15", Type: transactions.TypePayment, Status: transactions.StatusPaid, Amount: 12, Currency: "EUR", CreationDate: day(15),
+				Counterparty: &transactions.Counterparty{Name: "Acme", IBAN: "fr76 3000 6000 0112 3456 7890 189"}, Reference: "INV-1", Description: "Subscription", DueDate: &due},
+		},
+	}
+	return statement, batches
+}
+
+func writeStatement(t *testing.T) []byte {
+	t.Helper()
+	statement, batches := testStatement()
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, statement)
+	for _, batch := range batches {
+		if err := w.Write(batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriter(t *testing.T) {
+	out := writeStatement(t)
+
+	var document struct {
+		XMLName xml.Name `xml:"Document"`
+		MsgID   string   `xml:"BkToCstmrStmt>GrpHdr>MsgId"`
+		Entries []struct {
+			Reference   string   `xml:"NtryRef"`
+			BookingDate string   `xml:"BookgDt>Dt"`
+			Indicator   string   `xml:"CdtDbtInd"`
+			IBANs       []string `xml:"NtryDtls>TxDtls>RltdPties>CdtrAcct>Id>IBAN"`
+			DebtorIBANs []string `xml:"NtryDtls>TxDtls>RltdPties>DbtrAcct>Id>IBAN"`
+		} `xml:"BkToCstmrStmt>Stmt>Ntry"`
+	}
+	if err := xml.Unmarshal(out, &document); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, out)
+	}
+	if document.XMLName.Space != Namespace {
+		t.Errorf("namespace %q, want %q", document.XMLName.Space, Namespace)
+	}
+
+	if len(document.MsgID) > max35Text || !regexp.MustCompile(`^a+-\d+$`).MatchString(document.MsgID) {
+		t.Errorf("MsgId %q must keep its unique suffix within %d characters", document.MsgID, max35Text)
+	}
+
+	var refs []string
+	for _, e := range document.Entries {
+		refs = append(refs, e.Reference)
+	}
+	if got, want := strings.Join(refs, ","), "credit-3,refund-9,payment-15"; got != want {
+		t.Errorf("entries %s, want the booked ones %s", got, want)
+	}
+
+	payment := document.Entries[len(document.Entries)-1]
+	if payment.Indicator != "DBIT" || len(payment.IBANs) != 1 || payment.IBANs[0] != "FR7630006000011234567890189" {
+		t.Errorf("payment entry %+v, want a debit to the normalized IBAN", payment)
+	}
+	if credit := document.Entries[0]; len(credit.DebtorIBANs) != 0 {
+		t.Errorf("credit entry %+v, an invalid IBAN must be left out", credit)
+	}
+}
+
+func TestWriterMatchesSchema(t *testing.T) {
+	if _, err := os.Stat(schemaPath); err != nil {
+		t.Fatalf("%s not found, the output can not be validated without the official schema", schemaPath)
+	}
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil {
+		t.Fatal("xmllint not found, it validates the output against the schema")
+	}
+
+	path := filepath.Join(t.TempDir(), "camt053.xml")
+	if err := os.WriteFile(path, writeStatement(t), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command(xmllint, "--noout", "--schema", schemaPath, path).CombinedOutput(); err != nil {
+		t.Errorf("the statement does not match the schema: %v\n%s", err, out)
+	}
+}
+
+func TestWriterWritesTheEntriesAsTheyCome(t *testing.T) {
+	statement, batches := testStatement()
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, statement)
+	if err := w.Write(batches[0]); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "<NtryRef>credit-3</NtryRef>") {
+		t.Errorf("expected the entry of the first batch to be written before the next one is read:\n%s", buf.String())
+	}
+}
+
+// streamingService records the query of the streamed transactions
+type streamingService struct {
+	transactions.Service
+	query transactions.TransactionQuery
+}
+
+func (s *streamingService) GetStatement(ctx context.Context, query transactions.TransactionQuery) (*transactions.Statement, error) {
+	statement, _ := testStatement()
+	return statement, nil
+}
+
+func (s *streamingService) Stream(ctx context.Context, query transactions.TransactionQuery, fn func([]*transactions.Transaction) error) error {
+	s.query = query
+	return nil
+}
+
+func TestEndpointStreamsByBookingDate(t *testing.T) {
+	s := &streamingService{}
+	response, err := makeGetCamt053Endpoint(s)(context.Background(), transactions.NewTransactionQuery())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := response.(statementResponse).export(func([]*transactions.Transaction) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if sort := s.query.Sort; len(sort) == 0 || sort[0].Field != "creation_date" || !sort[0].Ascending {
+		t.Errorf("expected the transactions by ascending creation date, got %v", sort)
+	}
+}
@@ -0,0 +1,46 @@
+package camt
+
+import (
+	"context"
+
+	"github.com/fsilberstein/parameters-issue/transactions"
+	"github.com/go-kit/kit/endpoint"
+)
+
+// Endpoints represents all endpoints
+type Endpoints struct {
+	GetCamt053Endpoint endpoint.Endpoint
+}
+
+func MakeEndpoints(s transactions.Service) Endpoints {
+	return Endpoints{
+		GetCamt053Endpoint: makeGetCamt053Endpoint(s),
+	}
+}
+
+// statementResponse defers the listing of the entries to the response encoder, which streams them to the client
+type statementResponse struct {
+	statement *transactions.Statement
+	export    func(fn func([]*transactions.Transaction) error) error
+}
+
+func makeGetCamt053Endpoint(s transactions.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		query := request.(transactions.TransactionQuery)
+		// the entries are written as they come, by booking date
+		query.Sort = transactions.SortSpec{{Field: "creation_date", Ascending: true}}
+
+		// balances are computed before anything is streamed, so that a failure can still be reported properly
+		statement, err := s.GetStatement(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		return statementResponse{
+			statement: statement,
+			export: func(fn func([]*transactions.Transaction) error) error {
+				return s.Stream(ctx, query, fn)
+			},
+		}, nil
+	}
+}
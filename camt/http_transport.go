@@ -0,0 +1,63 @@
+package camt
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+	"github.com/fsilberstein/parameters-issue/transactions"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+)
+
+// MakeHTTPHandler ...
+func MakeHTTPHandler(endpoints Endpoints, router *mux.Router) http.Handler {
+
+	options := []kithttp.ServerOption{
+		kithttp.ServerBefore(kithttp.PopulateRequestContext),
+		kithttp.ServerErrorEncoder(errors.LoggingErrorEncoder),
+	}
+
+	getCamt053Handler := kithttp.NewServer(
+		endpoints.GetCamt053Endpoint,
+		decodeGetCamt053Request,
+		encodeCamt053Response,
+		options...,
+	)
+
+	ur := router.PathPrefix("/users").Subrouter().StrictSlash(true)
+	{
+		ur.Handle("/{id}/statements/camt053", getCamt053Handler).Methods("GET")
+	}
+
+	return router
+}
+
+// decodeGetCamt053Request accepts the filters of the transaction listings, a statement needs `date_from` and a
+// single `currency`
+func decodeGetCamt053Request(ctx context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+	if !ok {
+		return nil, errors.NewInvalidArgument("invalid parameter 'user_id'")
+	}
+
+	query := transactions.NewTransactionQuery()
+	query.UserID = &id
+
+	if err := transactions.DecodeFilters(r.URL.Query(), &query); err != nil {
+		return nil, err
+	}
+
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	return query, nil
+}
+
+func encodeCamt053Response(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	statement := response.(statementResponse)
+	writer := NewWriter(w, statement.statement)
+	return transactions.StreamExport(w, "application/xml; charset=utf-8", "camt053.xml", writer, statement.export)
+}
@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"time"
 
 	"github.com/fsilberstein/parameters-issue/logger"
 	"github.com/spf13/viper"
@@ -18,6 +20,24 @@ var (
 	ElasticHost         string
 	ElasticResponseSize int
 	ElasticDebug        bool
+	StatementBankID     string
+	StatementBIC        string
+	StatementBankName   string
+	BulkBatchSize       int
+	EventsFile          string
+	EventsDeadLetter    string
+	EventsMaxAttempts   int
+	WebhooksIndex       string
+	DeliveriesIndex     string
+	WebhooksWorkers     int
+	WebhooksMaxAttempts int
+	WebhooksBackoff     time.Duration
+	WebhooksTimeout     time.Duration
+	RulesIndex          string
+	DuplicatesIndex     string
+	DuplicatesWindow    time.Duration
+	DuplicatesAmountTol float64
+	DuplicatesFields    []string
 )
 
 func init() {
@@ -30,6 +50,20 @@ func InitConfig() {
 	viper.SetDefault("APP_PORT", "8080")
 	viper.SetDefault("ELASTIC_RESPONSE_SIZE", 10000)
 	viper.SetDefault("ELASTIC_DEBUG", false)
+	viper.SetDefault("STATEMENT_BANK_ID", "000000000")
+	viper.SetDefault("BULK_BATCH_SIZE", 500)
+	viper.SetDefault("EVENTS_MAX_ATTEMPTS", 5)
+	viper.SetDefault("WEBHOOKS_INDEX", "webhooks")
+	viper.SetDefault("WEBHOOKS_DELIVERIES_INDEX", "webhook-deliveries")
+	viper.SetDefault("WEBHOOKS_WORKERS", 4)
+	viper.SetDefault("WEBHOOKS_MAX_ATTEMPTS", 8)
+	viper.SetDefault("WEBHOOKS_INITIAL_BACKOFF", "30s")
+	viper.SetDefault("WEBHOOKS_TIMEOUT", "10s")
+	viper.SetDefault("RULES_INDEX", "rules")
+	viper.SetDefault("DUPLICATES_INDEX", "duplicate-resolutions")
+	viper.SetDefault("DUPLICATES_WINDOW", "5m")
+	viper.SetDefault("DUPLICATES_AMOUNT_TOLERANCE", 0)
+	viper.SetDefault("DUPLICATES_FIELDS", "counterparty")
 
 	if os.Getenv("ENVIRONMENT") == "development" || os.Getenv("ENVIRONMENT") == "DEV" {
 		_, dirname, _, _ := runtime.Caller(0)
@@ -52,4 +86,33 @@ func InitConfig() {
 	ElasticHost = viper.GetString("ELASTIC_HOST")
 	ElasticResponseSize = viper.GetInt("ELASTIC_RESPONSE_SIZE")
 	ElasticDebug = viper.GetBool("ELASTIC_DEBUG")
+	// Statements configuration
+	StatementBankID = viper.GetString("STATEMENT_BANK_ID")
+	StatementBIC = viper.GetString("STATEMENT_BIC")
+	StatementBankName = viper.GetString("STATEMENT_BANK_NAME")
+	// Ingestion configuration
+	BulkBatchSize = viper.GetInt("BULK_BATCH_SIZE")
+	// Events configuration, the consumer only runs when EVENTS_FILE is set
+	EventsFile = viper.GetString("EVENTS_FILE")
+	EventsDeadLetter = viper.GetString("EVENTS_DEAD_LETTER_FILE")
+	EventsMaxAttempts = viper.GetInt("EVENTS_MAX_ATTEMPTS")
+	// Webhooks configuration, a failed delivery is retried after WEBHOOKS_INITIAL_BACKOFF, then twice as long...
+	WebhooksIndex = viper.GetString("WEBHOOKS_INDEX")
+	DeliveriesIndex = viper.GetString("WEBHOOKS_DELIVERIES_INDEX")
+	WebhooksWorkers = viper.GetInt("WEBHOOKS_WORKERS")
+	WebhooksMaxAttempts = viper.GetInt("WEBHOOKS_MAX_ATTEMPTS")
+	WebhooksBackoff = viper.GetDuration("WEBHOOKS_INITIAL_BACKOFF")
+	WebhooksTimeout = viper.GetDuration("WEBHOOKS_TIMEOUT")
+	// Categorization rules configuration
+	RulesIndex = viper.GetString("RULES_INDEX")
+	// Duplicates configuration, the default similarity rule. DUPLICATES_FIELDS is a comma separated list.
+	DuplicatesIndex = viper.GetString("DUPLICATES_INDEX")
+	DuplicatesWindow = viper.GetDuration("DUPLICATES_WINDOW")
+	DuplicatesAmountTol = viper.GetFloat64("DUPLICATES_AMOUNT_TOLERANCE")
+	DuplicatesFields = nil
+	for _, field := range strings.Split(viper.GetString("DUPLICATES_FIELDS"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			DuplicatesFields = append(DuplicatesFields, field)
+		}
+	}
 }
@@ -0,0 +1,132 @@
+package duplicates
+
+import (
+	"sort"
+
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+// pair is two transaction IDs, the lowest first
+type pair [2]string
+
+func newPair(a, b string) pair {
+	if b < a {
+		a, b = b, a
+	}
+	return pair{a, b}
+}
+
+// distinctPairs lists the pairs of transactions resolved as not being duplicates
+func distinctPairs(resolutions []*Resolution) map[pair]bool {
+	distinct := make(map[pair]bool)
+	for _, resolution := range resolutions {
+		if resolution.Action != ActionNotDuplicate {
+			continue
+		}
+		for i, a := range resolution.TransactionIDs {
+			for _, b := range resolution.TransactionIDs[i+1:] {
+				distinct[newPair(a, b)] = true
+			}
+		}
+	}
+	return distinct
+}
+
+// node is a transaction of the detection. The similar transactions are linked into a tree rooted at the first
+// created one, the root holding the transactions of the group.
+type node struct {
+	transaction *transactions.Transaction
+	// seq is the rank of the transaction in the stream
+	seq     int
+	parent  *node
+	members []*transactions.Transaction
+}
+
+func (n *node) root() *node {
+	if n.parent == nil {
+		return n
+	}
+	n.parent = n.parent.root()
+	return n.parent
+}
+
+// detector groups the similar transactions as they are streamed by creation date, so that each one is only
+// compared to the ones created at most the window of the rule before it. Only the transactions of the window
+// and the ones already grouped are held in memory.
+type detector struct {
+	rule     SimilarityRule
+	distinct map[pair]bool
+	// window holds the last transactions, by creation date
+	window []*node
+	// roots are the roots of the groups of several transactions
+	roots map[*node]bool
+	seq   int
+}
+
+func newDetector(rule SimilarityRule, distinct map[pair]bool) *detector {
+	return &detector{rule: rule, distinct: distinct, roots: make(map[*node]bool)}
+}
+
+// add compares the transaction to the ones of the window, it must not be created before the ones added already
+func (d *detector) add(t *transactions.Transaction) {
+	start := 0
+	for start < len(d.window) && t.CreationDate.Sub(d.window[start].transaction.CreationDate) > d.rule.Window {
+		start++
+	}
+	d.window = d.window[start:]
+
+	n := &node{transaction: t, seq: d.seq, members: []*transactions.Transaction{t}}
+	d.seq++
+	for _, other := range d.window {
+		if d.distinct[newPair(other.transaction.ID, t.ID)] || !d.rule.similar(other.transaction, t) {
+			continue
+		}
+		d.union(other, n)
+	}
+	d.window = append(d.window, n)
+}
+
+// union merges the groups of the nodes, the group of the first created one taking the other
+func (d *detector) union(a, b *node) {
+	ra, rb := a.root(), b.root()
+	if ra == rb {
+		return
+	}
+	if rb.seq < ra.seq {
+		ra, rb = rb, ra
+	}
+	rb.parent = ra
+	ra.members = mergeMembers(ra.members, rb.members)
+	rb.members = nil
+	delete(d.roots, rb)
+	d.roots[ra] = true
+}
+
+// mergeMembers merges two lists of transactions sorted by creation date, the ones created at the same time by ID
+func mergeMembers(a, b []*transactions.Transaction) []*transactions.Transaction {
+	merged := make([]*transactions.Transaction, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		if b[0].CreationDate.Before(a[0].CreationDate) || b[0].CreationDate.Equal(a[0].CreationDate) && b[0].ID < a[0].ID {
+			merged, b = append(merged, b[0]), b[1:]
+		} else {
+			merged, a = append(merged, a[0]), a[1:]
+		}
+	}
+	merged = append(merged, a...)
+	return append(merged, b...)
+}
+
+// groups returns the groups, ordered by their first transaction
+func (d *detector) groups() []*Group {
+	roots := make([]*node, 0, len(d.roots))
+	for root := range d.roots {
+		roots = append(roots, root)
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].seq < roots[j].seq })
+
+	groups := make([]*Group, len(roots))
+	for i, root := range roots {
+		groups[i] = &Group{PrimaryID: root.transaction.ID, Transactions: root.members}
+	}
+	return groups
+}
@@ -0,0 +1,34 @@
+package duplicates
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// Endpoints represents all endpoints
+type Endpoints struct {
+	FindEndpoint    endpoint.Endpoint
+	ResolveEndpoint endpoint.Endpoint
+}
+
+func MakeEndpoints(s Service) Endpoints {
+	return Endpoints{
+		FindEndpoint:    makeFindEndpoint(s),
+		ResolveEndpoint: makeResolveEndpoint(s),
+	}
+}
+
+func makeFindEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(FindRequest)
+		return s.Find(ctx, req)
+	}
+}
+
+func makeResolveEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ResolveRequest)
+		return s.Resolve(ctx, req)
+	}
+}
@@ -0,0 +1,118 @@
+package duplicates
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+	"github.com/fsilberstein/parameters-issue/transactions"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+)
+
+// MakeHTTPHandler registers the duplicates routes. They must be registered before the transactions ones, which
+// would take `duplicates` for a transaction ID.
+func MakeHTTPHandler(endpoints Endpoints, router *mux.Router) http.Handler {
+
+	options := []kithttp.ServerOption{
+		kithttp.ServerBefore(kithttp.PopulateRequestContext),
+		kithttp.ServerErrorEncoder(errors.LoggingErrorEncoder),
+	}
+
+	findHandler := kithttp.NewServer(
+		endpoints.FindEndpoint,
+		decodeFindRequest,
+		encodeResponse(http.StatusOK),
+		options...,
+	)
+
+	resolveHandler := kithttp.NewServer(
+		endpoints.ResolveEndpoint,
+		decodeResolveRequest,
+		encodeResponse(http.StatusCreated),
+		options...,
+	)
+
+	ur := router.PathPrefix("/users").Subrouter().StrictSlash(true)
+	{
+		ur.Handle("/{id}/transactions/duplicates", findHandler).Methods("GET")
+		ur.Handle("/{id}/transactions/duplicates/_resolve", resolveHandler).Methods("POST")
+	}
+
+	return router
+}
+
+// decodeFindRequest accepts the filters of the transaction listings, and the similarity parameters `window`
+// (a duration like 30s), `amount_tolerance` and `match`, which can be repeated
+func decodeFindRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+	if !ok {
+		return nil, errors.NewInvalidArgument("invalid parameter 'user_id'")
+	}
+
+	request := FindRequest{Query: transactions.NewTransactionQuery()}
+	request.Query.UserID = &id
+
+	params := r.URL.Query()
+	if err := transactions.DecodeFilters(params, &request.Query); err != nil {
+		return nil, err
+	}
+
+	window, ok := params["window"]
+	if ok && len(window) > 0 {
+		d, err := time.ParseDuration(window[0])
+		if err != nil {
+			return nil, errors.NewInvalidArgument("invalid parameter 'window'")
+		}
+		request.Window = &d
+	}
+
+	amountTolerance, ok := params["amount_tolerance"]
+	if ok && len(amountTolerance) > 0 {
+		f, err := strconv.ParseFloat(amountTolerance[0], 64)
+		if err != nil {
+			return nil, errors.NewInvalidArgument("invalid parameter 'amount_tolerance'")
+		}
+		request.AmountTolerance = &f
+	}
+
+	if match, ok := params["match"]; ok && len(match) > 0 {
+		request.Fields = match
+	}
+
+	return request, nil
+}
+
+func decodeResolveRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+	if !ok {
+		return nil, errors.NewInvalidArgument("invalid parameter 'user_id'")
+	}
+
+	var request ResolveRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		return nil, errors.NewInvalidArgument("could not decode the resolution: " + err.Error())
+	}
+	request.UserID = id
+
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+func encodeResponse(status int) kithttp.EncodeResponseFunc {
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		return json.NewEncoder(w).Encode(response)
+	}
+}
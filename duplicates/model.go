@@ -0,0 +1,190 @@
+package duplicates
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+const (
+	// maxGroupSize bounds the number of transactions resolved at once
+	maxGroupSize = 50
+	// maxGroups is the number of groups returned by a search, the oldest first
+	maxGroups = 500
+	// maxScanRange bounds the date range searched for duplicates
+	maxScanRange = 366 * 24 * time.Hour
+	// defaultScanRange is the date range searched when the request sets none, back from now
+	defaultScanRange = 90 * 24 * time.Hour
+)
+
+// The actions resolving a group of duplicates
+const (
+	// ActionMerge keeps the primary transaction, the others are marked as its duplicates and hidden on demand
+	ActionMerge = "merge"
+	// ActionNotDuplicate records that the transactions are distinct, they are no longer grouped together
+	ActionNotDuplicate = "not_duplicate"
+	// ActionUnmerge undoes a merge: the transactions merged into the primary one are shown again
+	ActionUnmerge = "unmerge"
+)
+
+// similarityFields are the fields a similarity rule can require to be equal, with the way to read them
+var similarityFields = map[string]func(t *transactions.Transaction) string{
+	"type":        func(t *transactions.Transaction) string { return t.Type },
+	"reference":   func(t *transactions.Transaction) string { return t.Reference },
+	"description": func(t *transactions.Transaction) string { return t.Description },
+	"counterparty": func(t *transactions.Transaction) string {
+		if t.Counterparty == nil {
+			return ""
+		}
+		// the IBAN tells the counterparty apart better than a name spelled differently by each source
+		if t.Counterparty.IBAN != "" {
+			return t.Counterparty.IBAN
+		}
+		return t.Counterparty.Name
+	},
+}
+
+// SimilarityRule tells when two transactions are duplicate candidates: same currency, creation dates at most
+// Window apart, amounts at most AmountTolerance apart and equal Fields. Fields are compared case insensitively
+// and a field missing on either transaction does not match.
+type SimilarityRule struct {
+	Window          time.Duration `json:"window"`
+	AmountTolerance float64       `json:"amount_tolerance"`
+	Fields          []string      `json:"fields"`
+}
+
+// Validate checks the bounds and the fields of the rule
+func (r SimilarityRule) Validate() error {
+	if r.Window < 0 || r.Window > 24*time.Hour {
+		return errors.NewInvalidArgument("parameter 'window' must be between 0 and 24h")
+	}
+	if r.AmountTolerance < 0 {
+		return errors.NewInvalidArgument("parameter 'amount_tolerance' can not be negative")
+	}
+	for _, field := range r.Fields {
+		if _, ok := similarityFields[field]; !ok {
+			return errors.NewInvalidArgument(fmt.Sprintf("invalid parameter 'match': unknown field '%s'", field))
+		}
+	}
+	return nil
+}
+
+// similar tells whether the transactions are duplicate candidates
+func (r SimilarityRule) similar(a, b *transactions.Transaction) bool {
+	gap := a.CreationDate.Sub(b.CreationDate)
+	if gap < 0 {
+		gap = -gap
+	}
+	if gap > r.Window || a.Currency != b.Currency || math.Abs(a.Amount-b.Amount) > r.AmountTolerance+1e-9 {
+		return false
+	}
+
+	for _, field := range r.Fields {
+		read := similarityFields[field]
+		va, vb := strings.TrimSpace(read(a)), strings.TrimSpace(read(b))
+		if va == "" || !strings.EqualFold(va, vb) {
+			return false
+		}
+	}
+	return true
+}
+
+// FindRequest searches the duplicates among the transactions matching Query, Query holding the user. The
+// similarity rule is the configured one, with the fields set in the request replacing its own.
+type FindRequest struct {
+	Query           transactions.TransactionQuery `json:"query"`
+	Window          *time.Duration                `json:"window"`
+	AmountTolerance *float64                      `json:"amount_tolerance"`
+	Fields          []string                      `json:"fields"`
+}
+
+// rule returns the similarity rule of the request, based on the default one
+func (r FindRequest) rule(defaultRule SimilarityRule) SimilarityRule {
+	rule := defaultRule
+	if r.Window != nil {
+		rule.Window = *r.Window
+	}
+	if r.AmountTolerance != nil {
+		rule.AmountTolerance = *r.AmountTolerance
+	}
+	if r.Fields != nil {
+		rule.Fields = r.Fields
+	}
+	return rule
+}
+
+// Validate checks the query and the span of its date range
+func (r FindRequest) Validate() error {
+	if r.Query.UserID == nil || *r.Query.UserID == "" {
+		return errors.NewInvalidArgument("invalid parameter 'user_id'")
+	}
+	if r.Query.DateFrom == nil || r.Query.DateTo == nil || r.Query.DateTo.Sub(*r.Query.DateFrom) > maxScanRange {
+		return errors.NewInvalidArgument("the date range can not span more than a year")
+	}
+	return r.Query.Validate()
+}
+
+// Group is a set of transactions similar to each other, directly or through another transaction of the group.
+// PrimaryID suggests the transaction to keep: the first created.
+type Group struct {
+	PrimaryID    string                      `json:"primary_id"`
+	Transactions []*transactions.Transaction `json:"transactions"`
+}
+
+// FindResult lists the groups of duplicates, oldest first. Total counts all of them, Groups holds the first ones.
+type FindResult struct {
+	Total  int      `json:"total"`
+	Groups []*Group `json:"groups"`
+}
+
+// Resolution records how a group of duplicates was resolved
+type Resolution struct {
+	ID             string    `json:"id"`
+	UserID         string    `json:"user_id"`
+	Action         string    `json:"action"`
+	PrimaryID      string    `json:"primary_id,omitempty"`
+	TransactionIDs []string  `json:"transaction_ids"`
+	CreationDate   time.Time `json:"creation_date"`
+}
+
+// ResolveRequest asks to resolve a group of duplicates. PrimaryID is the transaction kept by a merge, or the one
+// the transactions to unmerge were merged into.
+type ResolveRequest struct {
+	UserID         string   `json:"-"`
+	Action         string   `json:"action"`
+	PrimaryID      string   `json:"primary_id"`
+	TransactionIDs []string `json:"transaction_ids"`
+}
+
+// Validate checks the action and the transactions of the group
+func (r ResolveRequest) Validate() error {
+	if r.UserID == "" {
+		return errors.NewInvalidArgument("invalid parameter 'user_id'")
+	}
+	if r.Action != ActionMerge && r.Action != ActionNotDuplicate && r.Action != ActionUnmerge {
+		return errors.NewInvalidArgument(fmt.Sprintf("field 'action' must be '%s', '%s' or '%s'", ActionMerge, ActionNotDuplicate, ActionUnmerge))
+	}
+
+	if len(r.TransactionIDs) < 2 || len(r.TransactionIDs) > maxGroupSize {
+		return errors.NewInvalidArgument(fmt.Sprintf("field 'transaction_ids' must hold from 2 to %d transactions", maxGroupSize))
+	}
+	seen := make(map[string]bool, len(r.TransactionIDs))
+	for _, id := range r.TransactionIDs {
+		if id == "" || seen[id] {
+			return errors.NewInvalidArgument("field 'transaction_ids' can not hold empty or repeated transactions")
+		}
+		seen[id] = true
+	}
+
+	switch {
+	case r.Action != ActionNotDuplicate && !seen[r.PrimaryID]:
+		return errors.NewInvalidArgument(fmt.Sprintf("field 'primary_id' must be one of the transactions to %s", r.Action))
+	case r.Action == ActionNotDuplicate && r.PrimaryID != "":
+		return errors.NewInvalidArgument("field 'primary_id' is only accepted by a merge or an unmerge")
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+package duplicates
+
+import "context"
+
+// Repository persists the resolutions of the duplicates
+type Repository interface {
+	Create(ctx context.Context, resolution *Resolution) error
+	// GetByUser lists the resolutions of the user with the given action
+	GetByUser(ctx context.Context, userID string, action string) ([]*Resolution, error)
+}
@@ -0,0 +1,218 @@
+package duplicates
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+// Service is the duplicates service interface
+type Service interface {
+	// Find groups the similar transactions of a user, leaving out the ones already merged and the ones resolved
+	// as distinct
+	Find(ctx context.Context, request FindRequest) (*FindResult, error)
+	// Resolve merges a group of duplicates, undoes a merge or records that its transactions are distinct
+	Resolve(ctx context.Context, request ResolveRequest) (*Resolution, error)
+}
+
+type service struct {
+	repo            Repository
+	transactions    transactions.Service
+	transactionRepo transactions.Repository
+	rule            SimilarityRule
+}
+
+// NewService initializes new service. The transactions are searched through transactionsService and merged
+// through transactionRepo, rule is the similarity rule used when a search sets none.
+func NewService(repo Repository, transactionsService transactions.Service, transactionRepo transactions.Repository, rule SimilarityRule) (Service, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+	return &service{
+		repo:            repo,
+		transactions:    transactionsService,
+		transactionRepo: transactionRepo,
+		rule:            rule,
+	}, nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Find searches the last days when the request sets no date range, see defaultScanRange
+func (s *service) Find(ctx context.Context, request FindRequest) (*FindResult, error) {
+	if request.Query.DateTo == nil {
+		now := time.Now()
+		request.Query.DateTo = &now
+	}
+	if request.Query.DateFrom == nil {
+		from := request.Query.DateTo.Add(-defaultScanRange)
+		request.Query.DateFrom = &from
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	rule := request.rule(s.rule)
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	query := request.Query
+	query.HideDuplicates = true
+	// the detector compares each transaction to the ones created just before it
+	query.Sort = transactions.SortSpec{{Field: "creation_date", Ascending: true}}
+	resolutions, err := s.repo.GetByUser(ctx, *query.UserID, ActionNotDuplicate)
+	if err != nil {
+		return nil, err
+	}
+
+	d := newDetector(rule, distinctPairs(resolutions))
+	err = s.transactions.Stream(ctx, query, func(page []*transactions.Transaction) error {
+		for _, t := range page {
+			d.add(t)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	groups := d.groups()
+	result := &FindResult{Total: len(groups), Groups: groups}
+	if len(groups) > maxGroups {
+		result.Groups = groups[:maxGroups]
+	}
+	return result, nil
+}
+
+// Resolve checks that the transactions belong to the user. A merge adds the tags of the duplicates to the
+// primary transaction, then marks the duplicates. An unmerge clears the marks, the tags added to the primary
+// transaction are kept. Either one interrupted midway can be sent again: the transactions already merged into
+// the primary transaction, or already unmerged, are accepted.
+func (s *service) Resolve(ctx context.Context, request ResolveRequest) (*Resolution, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	list := make([]*transactions.Transaction, 0, len(request.TransactionIDs))
+	var primary *transactions.Transaction
+	for _, id := range request.TransactionIDs {
+		t, err := s.transactionRepo.GetByID(ctx, id)
+		if owned, ok := err.(transactions.OwnedError); ok && owned.Owner() != request.UserID {
+			return nil, errors.NewNotFoundError("transaction")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if t.UserID != request.UserID {
+			return nil, errors.NewNotFoundError("transaction")
+		}
+		if id == request.PrimaryID {
+			primary = t
+		}
+		if err := checkMerged(request, t); err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+
+	switch request.Action {
+	case ActionMerge:
+		if err := s.merge(ctx, primary, list); err != nil {
+			return nil, err
+		}
+	case ActionUnmerge:
+		if err := s.unmerge(ctx, primary, list); err != nil {
+			return nil, err
+		}
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	resolution := &Resolution{
+		ID:             id,
+		UserID:         request.UserID,
+		Action:         request.Action,
+		PrimaryID:      request.PrimaryID,
+		TransactionIDs: request.TransactionIDs,
+		CreationDate:   time.Now().UTC(),
+	}
+	if err := s.repo.Create(ctx, resolution); err != nil {
+		return nil, err
+	}
+	return resolution, nil
+}
+
+func (s *service) merge(ctx context.Context, primary *transactions.Transaction, list []*transactions.Transaction) error {
+	tags := append([]string(nil), primary.Tags...)
+	for _, t := range list {
+		tags = append(tags, t.Tags...)
+	}
+	tags = transactions.NormalizeTags(tags)
+	if len(tags) != len(primary.Tags) {
+		patch := transactions.TransactionPatch{Tags: &tags}
+		if err := (transactions.UpdateRequest{Version: primary.Version, Patch: patch}).Validate(); err != nil {
+			return err
+		}
+		// the version makes the merge fail rather than overwrite tags edited meanwhile
+		if err := s.transactionRepo.Update(ctx, primary.ID, patch, primary.Version); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range list {
+		if t.ID == primary.ID || t.DuplicateOf == primary.ID {
+			continue
+		}
+		if err := s.markDuplicate(ctx, t, primary.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *service) unmerge(ctx context.Context, primary *transactions.Transaction, list []*transactions.Transaction) error {
+	for _, t := range list {
+		if t.ID == primary.ID || t.DuplicateOf == "" {
+			continue
+		}
+		if err := s.markDuplicate(ctx, t, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markDuplicate marks the transaction as merged into primaryID, or clears the mark when primaryID is empty. Only
+// the mark is written, at the version read, so that an edit made meanwhile is neither lost nor overwritten.
+func (s *service) markDuplicate(ctx context.Context, t *transactions.Transaction, primaryID string) error {
+	patch := transactions.TransactionPatch{DuplicateOf: &primaryID}
+	if err := s.transactionRepo.Update(ctx, t.ID, patch, t.Version); err != nil {
+		return err
+	}
+	t.DuplicateOf = primaryID
+	return nil
+}
+
+// checkMerged checks that the merge state of a transaction allows the action: a transaction merged into another
+// one can only be unmerged from it, or merged again into it by a retried merge
+func checkMerged(request ResolveRequest, t *transactions.Transaction) error {
+	if t.DuplicateOf == "" || (t.ID == request.PrimaryID && request.Action == ActionUnmerge) {
+		return nil
+	}
+	if request.Action == ActionNotDuplicate || t.DuplicateOf != request.PrimaryID {
+		return errors.NewConflictError("transaction '" + t.ID + "' is already merged into another one")
+	}
+	return nil
+}
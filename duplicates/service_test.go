@@ -0,0 +1,243 @@
+package duplicates
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+// memoryTransactions stores transactions by ID, a write increments their version
+type memoryTransactions struct {
+	transactions.Repository
+	stored map[string]*transactions.Transaction
+}
+
+func (m *memoryTransactions) GetByID(ctx context.Context, transactionID string) (*transactions.Transaction, error) {
+	t, ok := m.stored[transactionID]
+	if !ok {
+		return nil, errors.NewNotFoundError("transaction")
+	}
+	copied := *t
+	return &copied, nil
+}
+
+func (m *memoryTransactions) Update(ctx context.Context, transactionID string, patch transactions.TransactionPatch, version int64) error {
+	t, ok := m.stored[transactionID]
+	if !ok {
+		return errors.NewNotFoundError("transaction")
+	}
+	if t.Version != version {
+		return errors.NewConflictError("the transaction was modified")
+	}
+	if patch.Tags != nil {
+		t.Tags = *patch.Tags
+	}
+	if patch.DuplicateOf != nil {
+		t.DuplicateOf = *patch.DuplicateOf
+	}
+	t.Version++
+	return nil
+}
+
+// streamingService streams the stored transactions by creation date, two at a time
+type streamingService struct {
+	transactions.Service
+	repo *memoryTransactions
+}
+
+func (s *streamingService) Stream(ctx context.Context, query transactions.TransactionQuery, fn func([]*transactions.Transaction) error) error {
+	if len(query.Sort) == 0 || query.Sort[0].Field != "creation_date" || !query.Sort[0].Ascending {
+		return errors.NewInvalidArgument("expected the transactions by ascending creation date")
+	}
+	var list []*transactions.Transaction
+	for _, t := range s.repo.stored {
+		if !query.HideDuplicates || t.DuplicateOf == "" {
+			copied := *t
+			list = append(list, &copied)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreationDate.Equal(list[j].CreationDate) {
+			return list[i].CreationDate.Before(list[j].CreationDate)
+		}
+		return list[i].ID < list[j].ID
+	})
+	for len(list) > 0 {
+		size := 2
+		if len(list) < size {
+			size = len(list)
+		}
+		if err := fn(list[:size]); err != nil {
+			return err
+		}
+		list = list[size:]
+	}
+	return nil
+}
+
+// memoryResolutions keeps the resolutions in memory
+type memoryResolutions struct {
+	resolutions []*Resolution
+}
+
+func (r *memoryResolutions) Create(ctx context.Context, resolution *Resolution) error {
+	r.resolutions = append(r.resolutions, resolution)
+	return nil
+}
+
+func (r *memoryResolutions) GetByUser(ctx context.Context, userID string, action string) ([]*Resolution, error) {
+	var result []*Resolution
+	for _, resolution := range r.resolutions {
+		if resolution.UserID == userID && resolution.Action == action {
+			result = append(result, resolution)
+		}
+	}
+	return result, nil
+}
+
+func newTestService(t *testing.T, stored ...*transactions.Transaction) (Service, *memoryTransactions) {
+	m := &memoryTransactions{stored: make(map[string]*transactions.Transaction)}
+	for _, transaction := range stored {
+		transaction.UserID = "u1"
+		transaction.Currency = "EUR"
+		transaction.Version = 1
+		m.stored[transaction.ID] = transaction
+	}
+	s, err := NewService(&memoryResolutions{}, &streamingService{repo: m}, m, SimilarityRule{Window: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, m
+}
+
+func find(t *testing.T, s Service, from time.Time) []*Group {
+	userID := "u1"
+	to := from.Add(30 * 24 * time.Hour)
+	query := transactions.NewTransactionQuery()
+	query.UserID = &userID
+	query.DateFrom, query.DateTo = &from, &to
+	result, err := s.Find(context.Background(), FindRequest{Query: query})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result.Groups
+}
+
+func groupIDs(groups []*Group) [][]string {
+	ids := make([][]string, len(groups))
+	for i, group := range groups {
+		for _, t := range group.Transactions {
+			ids[i] = append(ids[i], t.ID)
+		}
+	}
+	return ids
+}
+
+func TestFindGroupsTheStreamedTransactions(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	s, _ := newTestService(t,
+		&transactions.Transaction{ID: "a", Amount: 10, CreationDate: start},
+		&transactions.Transaction{ID: "b", Amount: 20, CreationDate: start.Add(time.Hour)},
+		// streamed after e, it is grouped with b and e
+		&transactions.Transaction{ID: "d", Amount: 20, CreationDate: start.Add(20 * time.Hour)},
+		&transactions.Transaction{ID: "c", Amount: 10, CreationDate: start.Add(2 * time.Hour)},
+		&transactions.Transaction{ID: "e", Amount: 20, CreationDate: start.Add(3 * time.Hour)},
+		// out of the window of c
+		&transactions.Transaction{ID: "f", Amount: 10, CreationDate: start.Add(50 * time.Hour)},
+		&transactions.Transaction{ID: "g", Amount: 30, CreationDate: start.Add(80 * time.Hour)},
+		&transactions.Transaction{ID: "h", Amount: 30, CreationDate: start.Add(80 * time.Hour)},
+	)
+
+	groups := groupIDs(find(t, s, start))
+	expected := [][]string{{"a", "c"}, {"b", "e", "d"}, {"g", "h"}}
+	if len(groups) != len(expected) {
+		t.Fatalf("expected the groups %v, got %v", expected, groups)
+	}
+	for i := range expected {
+		if len(groups[i]) != len(expected[i]) {
+			t.Fatalf("expected the groups %v, got %v", expected, groups)
+		}
+		for j := range expected[i] {
+			if groups[i][j] != expected[i][j] {
+				t.Fatalf("expected the groups %v, got %v", expected, groups)
+			}
+		}
+	}
+}
+
+func TestUnmergeShowsTheDuplicatesAgain(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	s, m := newTestService(t,
+		&transactions.Transaction{ID: "a", Amount: 10, CreationDate: start, Tags: []string{"rent"}},
+		&transactions.Transaction{ID: "b", Amount: 10, CreationDate: start.Add(time.Hour), Tags: []string{"home"}},
+		&transactions.Transaction{ID: "c", Amount: 10, CreationDate: start.Add(2 * time.Hour)},
+	)
+	ctx := context.Background()
+
+	merge := ResolveRequest{UserID: "u1", Action: ActionMerge, PrimaryID: "a", TransactionIDs: []string{"a", "b"}}
+	if _, err := s.Resolve(ctx, merge); err != nil {
+		t.Fatal(err)
+	}
+	if m.stored["b"].DuplicateOf != "a" || len(m.stored["a"].Tags) != 2 {
+		t.Fatalf("expected b to be merged into a, got %+v", m.stored["b"])
+	}
+	if groups := groupIDs(find(t, s, start)); len(groups) != 1 || len(groups[0]) != 2 {
+		t.Errorf("expected the merged transaction to be hidden, got %v", groups)
+	}
+
+	// c was never merged into a, b is not merged into c
+	_, err := s.Resolve(ctx, ResolveRequest{UserID: "u1", Action: ActionUnmerge, PrimaryID: "c", TransactionIDs: []string{"c", "b"}})
+	if coded, ok := err.(interface{ StatusCode() int }); !ok || coded.StatusCode() != http.StatusConflict {
+		t.Errorf("expected unmerging from another transaction to conflict, got %v", err)
+	}
+
+	unmerge := ResolveRequest{UserID: "u1", Action: ActionUnmerge, PrimaryID: "a", TransactionIDs: []string{"a", "b", "c"}}
+	for i := 0; i < 2; i++ {
+		if _, err := s.Resolve(ctx, unmerge); err != nil {
+			t.Fatalf("unmerge %d: %v", i, err)
+		}
+	}
+	if m.stored["b"].DuplicateOf != "" {
+		t.Errorf("expected b to be unmerged, got %+v", m.stored["b"])
+	}
+	if groups := groupIDs(find(t, s, start)); len(groups) != 1 || len(groups[0]) != 3 {
+		t.Errorf("expected the unmerged transaction to be grouped again, got %v", groups)
+	}
+}
+
+// unreadableError is the error of a stored transaction which can not be read, owned by another user
+type unreadableError struct{}
+
+func (unreadableError) Error() string { return "unreadable transaction" }
+func (unreadableError) Owner() string { return "u2" }
+
+// unreadableTransactions fails to read the transaction with the ID
+type unreadableTransactions struct {
+	*memoryTransactions
+	id string
+}
+
+func (u *unreadableTransactions) GetByID(ctx context.Context, transactionID string) (*transactions.Transaction, error) {
+	if transactionID == u.id {
+		return nil, unreadableError{}
+	}
+	return u.memoryTransactions.GetByID(ctx, transactionID)
+}
+
+func TestResolveHidesTheUnreadableTransactionsOfOtherUsers(t *testing.T) {
+	_, m := newTestService(t, &transactions.Transaction{ID: "a", Amount: 10})
+	s, err := NewService(&memoryResolutions{}, &streamingService{repo: m}, &unreadableTransactions{memoryTransactions: m, id: "b"}, SimilarityRule{Window: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Resolve(context.Background(), ResolveRequest{UserID: "u1", Action: ActionMerge, PrimaryID: "a", TransactionIDs: []string{"a", "b"}})
+	if coded, ok := err.(interface{ StatusCode() int }); !ok || coded.StatusCode() != http.StatusNotFound {
+		t.Errorf("expected the transaction of another user to be missing, got %v", err)
+	}
+}
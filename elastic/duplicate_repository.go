@@ -0,0 +1,86 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/fsilberstein/parameters-issue/duplicates"
+	"github.com/pkg/errors"
+	elasticapi "gopkg.in/olivere/elastic.v5"
+)
+
+const DocumentTypeResolution = "resolution"
+
+type duplicateRepository struct {
+	IndexName     string
+	elasticClient *elasticapi.Client
+}
+
+// NewDuplicateRepository ...
+func NewDuplicateRepository(indexName string, elasticClient *elasticapi.Client) duplicates.Repository {
+	return &duplicateRepository{
+		IndexName:     indexName,
+		elasticClient: elasticClient,
+	}
+}
+
+// CreateDuplicateIndex creates the index of the resolutions with its mapping, the resolutions being read by user
+// and action
+func CreateDuplicateIndex(ctx context.Context, elasticClient *elasticapi.Client, indexName string) error {
+	return createIndex(ctx, elasticClient, indexName, DocumentTypeResolution, keywords("id", "user_id", "action", "primary_id", "transaction_ids"))
+}
+
+func (repo *duplicateRepository) Create(ctx context.Context, resolution *duplicates.Resolution) error {
+	if repo.elasticClient == nil {
+		return ErrElasticSearchNotReachable
+	}
+
+	_, err := repo.elasticClient.Index().
+		Index(repo.IndexName).
+		Type(DocumentTypeResolution).
+		Id(resolution.ID).
+		OpType("create").
+		BodyJson(resolution).
+		Refresh("wait_for"). // the next search leaves the resolved group out
+		Do(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error during elastic index")
+	}
+	return nil
+}
+
+func (repo *duplicateRepository) GetByUser(ctx context.Context, userID string, action string) ([]*duplicates.Resolution, error) {
+	if repo.elasticClient == nil {
+		return nil, ErrElasticSearchNotReachable
+	}
+
+	searchResult, err := repo.elasticClient.Search(repo.IndexName).
+		Index(repo.IndexName).
+		Type(DocumentTypeResolution).
+		Query(elasticapi.NewBoolQuery().Filter(
+			elasticapi.NewTermQuery("user_id", userID),
+			elasticapi.NewTermQuery("action", action),
+		)).
+		Size(elasticResponseSize).
+		Do(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error during elastic search")
+	}
+
+	list := []*duplicates.Resolution{}
+	if searchResult.Hits == nil {
+		return list, nil
+	}
+	for _, hit := range searchResult.Hits.Hits {
+		if hit.Source == nil {
+			continue
+		}
+		var resolution duplicates.Resolution
+		if err := json.Unmarshal(*hit.Source, &resolution); err != nil {
+			return nil, errors.Wrap(err, "malformed resolution document")
+		}
+		resolution.ID = hit.Id
+		list = append(list, &resolution)
+	}
+	return list, nil
+}
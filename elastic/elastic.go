@@ -11,6 +11,7 @@ import (
 
 	"github.com/facebookgo/httpcontrol"
 	"github.com/fsilberstein/parameters-issue/logger"
+	"github.com/fsilberstein/parameters-issue/transactions"
 	loghttp "github.com/motemen/go-loghttp"
 	"github.com/motemen/go-nuts/roundtime"
 	elasticapi "gopkg.in/olivere/elastic.v5"
@@ -118,9 +119,12 @@ func ping(ctx context.Context, client *elasticapi.Client, url string) error {
 	return errors.New("elastic client is nil")
 }
 
-func getSort(sort string) *elasticapi.FieldSort {
-	if sort == "asc" {
-		return elasticapi.NewFieldSort("creation_date").Asc()
+// getSort translates the sort specification and appends a tie-breaker on the document ID so that documents
+// sharing the same sort values always come in the same order. _id is not sortable in ES 5, _uid (type#id) is.
+func getSort(sort transactions.SortSpec) []elasticapi.Sorter {
+	sorters := make([]elasticapi.Sorter, 0, len(sort)+1)
+	for _, field := range sort {
+		sorters = append(sorters, elasticapi.NewFieldSort(field.Field).Order(field.Ascending))
 	}
-	return elasticapi.NewFieldSort("creation_date").Desc()
+	return append(sorters, elasticapi.NewFieldSort("_uid").Asc())
 }
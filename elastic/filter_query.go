@@ -0,0 +1,65 @@
+package elastic
+
+import (
+	"github.com/fsilberstein/parameters-issue/transactions"
+	elasticapi "gopkg.in/olivere/elastic.v5"
+)
+
+// getFilterQuery translates the AST of a filter expression into an ElasticSearch query
+func getFilterQuery(expr transactions.FilterExpr) elasticapi.Query {
+	switch e := expr.(type) {
+	case transactions.FilterAnd:
+		return elasticapi.NewBoolQuery().Must(getFilterQueries(e.Operands)...)
+	case transactions.FilterOr:
+		return elasticapi.NewBoolQuery().Should(getFilterQueries(e.Operands)...).MinimumNumberShouldMatch(1)
+	case transactions.FilterNot:
+		return elasticapi.NewBoolQuery().MustNot(getFilterQuery(e.Operand))
+	case transactions.FilterComparison:
+		return getComparisonQuery(e)
+	case transactions.FilterExists:
+		return elasticapi.NewExistsQuery(e.Field)
+	case transactions.FilterNone:
+		return elasticapi.NewMatchNoneQuery()
+	}
+	// the transactions package only produces the nodes above, match nothing rather than everything
+	return elasticapi.NewMatchNoneQuery()
+}
+
+func getFilterQueries(exprs []transactions.FilterExpr) []elasticapi.Query {
+	queries := make([]elasticapi.Query, len(exprs))
+	for i, expr := range exprs {
+		queries[i] = getFilterQuery(expr)
+	}
+	return queries
+}
+
+func getComparisonQuery(c transactions.FilterComparison) elasticapi.Query {
+	value := c.Value
+	if status, ok := value.(transactions.Status); ok {
+		value = string(status)
+	}
+
+	switch c.Operator {
+	case transactions.FilterEqual:
+		return getEqualQuery(c.Field, value)
+	case transactions.FilterNotEqual:
+		return elasticapi.NewBoolQuery().MustNot(getEqualQuery(c.Field, value))
+	case transactions.FilterGreater:
+		return elasticapi.NewRangeQuery(c.Field).Gt(value)
+	case transactions.FilterGreaterOrEqual:
+		return elasticapi.NewRangeQuery(c.Field).Gte(value)
+	case transactions.FilterLower:
+		return elasticapi.NewRangeQuery(c.Field).Lt(value)
+	case transactions.FilterLowerOrEqual:
+		return elasticapi.NewRangeQuery(c.Field).Lte(value)
+	case transactions.FilterContains:
+		// the text fields are analyzed, a phrase query matches the words in sequence whatever their case
+		return elasticapi.NewMatchPhraseQuery(c.Field, value)
+	}
+	return elasticapi.NewMatchNoneQuery()
+}
+
+// getEqualQuery matches a field against a whole value, see keywordField
+func getEqualQuery(field string, value interface{}) elasticapi.Query {
+	return elasticapi.NewTermQuery(keywordField(field), value)
+}
@@ -0,0 +1,93 @@
+package elastic
+
+import (
+	"testing"
+
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+func TestFilterQueryOnType(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{`type = fee`, true},
+		{`type != fee`, false},
+		{`type = invoice`, false},
+		{`type = invoice OR type = fee`, true},
+		{`type = fee AND amount > 10`, true},
+		{`type = fee AND status = open`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			expr, err := transactions.ParseFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if got := matchDocument(t, getFilterQuery(expr), storedFee); got != tt.want {
+				t.Errorf("matched the stored fee: %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFilterQueryMatchesLikeMatchFilter checks that the repository keeps the transactions MatchFilter keeps, the
+// rules being evaluated in memory and turned into queries for the category filter
+func TestFilterQueryMatchesLikeMatchFilter(t *testing.T) {
+	documents := []string{
+		storedFee,
+		`{"user_id":"u1","type":"payment","status":"open","amount":99,"currency":"EUR","creation_date":"2026-01-10T10:00:00Z","counterparty":{"name":"ACME Co. Ltd"},"reference":"INV-2026/001","description":"Monthly rent, March","tags":["home","VAT exempt"],"category":"Rent"}`,
+		`{"user_id":"u1","type":"payment","status":"open","amount":5,"currency":"USD","creation_date":"2026-01-12T10:00:00Z","due_date":"2026-02-01T00:00:00Z","counterparty":{"name":"Acmex"}}`,
+	}
+	filters := []string{
+		`counterparty.name ~ acme`,
+		`counterparty.name ~ "acme co"`,
+		`counterparty.name ~ "co acme"`,
+		`counterparty.name ~ acm`,
+		`counterparty.name = "ACME Co. Ltd"`,
+		`counterparty.name = "acme co. ltd"`,
+		`counterparty.name = acme`,
+		`counterparty.name != acmex`,
+		`reference = "INV-2026/001"`,
+		`reference ~ "2026 001"`,
+		`reference ~ inv-2026`,
+		`description ~ "rent march"`,
+		`description ~ RENT`,
+		`tags = home`,
+		`tags = "VAT exempt"`,
+		`tags = vat`,
+		`currency = eur AND amount >= 12.5`,
+		`NOT status = open OR due_date < 2026-03-01T00:00:00Z`,
+	}
+
+	exprs := make(map[string]transactions.FilterExpr, len(filters))
+	for _, filter := range filters {
+		expr, err := transactions.ParseFilter(filter)
+		if err != nil {
+			t.Fatalf("parse %s: %v", filter, err)
+		}
+		exprs[filter] = expr
+	}
+	// the category is not filtered on by the users, the rules build its comparisons
+	for _, category := range []string{"Rent", "rent"} {
+		exprs["category = "+category] = transactions.FilterComparison{Field: "category", Operator: transactions.FilterEqual, Value: category}
+	}
+
+	for _, document := range documents {
+		transaction, err := decodeTransaction("t", []byte(document))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for filter, expr := range exprs {
+			inMemory := transactions.MatchFilter(expr, transaction)
+			if stored := matchDocument(t, getFilterQuery(expr), document); stored != inMemory {
+				t.Errorf("%s on %s: the repository matches %v, MatchFilter %v", filter, document, stored, inMemory)
+			}
+		}
+	}
+
+	if matchDocument(t, getFilterQuery(transactions.FilterNone{}), storedFee) || transactions.MatchFilter(transactions.FilterNone{}, &transactions.Transaction{}) {
+		t.Error("expected FilterNone to match no transaction")
+	}
+}
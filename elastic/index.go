@@ -0,0 +1,49 @@
+package elastic
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	elasticapi "gopkg.in/olivere/elastic.v5"
+)
+
+// createIndex creates the index with the mapping of its document type, unless it exists already. The mapping
+// lists the fields the repositories look documents up by with term queries: the dynamic mapping would make them
+// analyzed text, an ID holding upper case letters or dashes then matching nothing.
+func createIndex(ctx context.Context, client *elasticapi.Client, index, documentType string, properties map[string]interface{}) error {
+	if client == nil {
+		return ErrElasticSearchNotReachable
+	}
+
+	exists, err := client.IndexExists(index).Do(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error during elastic index exists")
+	}
+	if exists {
+		return nil
+	}
+
+	body := map[string]interface{}{
+		"mappings": map[string]interface{}{
+			documentType: map[string]interface{}{"properties": properties},
+		},
+	}
+	_, err = client.CreateIndex(index).BodyJson(body).Do(ctx)
+	if e, ok := err.(*elasticapi.Error); ok && e.Details != nil && e.Details.Type == "index_already_exists_exception" {
+		// another instance created it meanwhile
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "error during elastic create index")
+	}
+	return nil
+}
+
+// keywords maps the fields as keywords, matched as a whole
+func keywords(fields ...string) map[string]interface{} {
+	properties := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		properties[field] = map[string]interface{}{"type": "keyword"}
+	}
+	return properties
+}
@@ -0,0 +1,96 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeIndexServer knows the existing indices and records the mappings of the ones created
+type fakeIndexServer struct {
+	mu       sync.Mutex
+	existing map[string]bool
+	created  map[string]map[string]interface{}
+}
+
+func (f *fakeIndexServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	index := strings.Trim(r.URL.Path, "/")
+	switch r.Method {
+	case http.MethodHead:
+		if !f.existing[index] {
+			http.NotFound(w, r)
+		}
+	case http.MethodPut:
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.existing[index] = true
+		f.created[index] = body
+		json.NewEncoder(w).Encode(map[string]interface{}{"acknowledged": true})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// fieldType reads the type of a field in the mapping of a created index
+func (f *fakeIndexServer) fieldType(index, documentType, field string) interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	mappings, _ := f.created[index]["mappings"].(map[string]interface{})
+	mapping, _ := mappings[documentType].(map[string]interface{})
+	properties, _ := mapping["properties"].(map[string]interface{})
+	property, _ := properties[field].(map[string]interface{})
+	return property["type"]
+}
+
+func TestCreateWebhookIndicesMapsTheIDsAsKeywords(t *testing.T) {
+	fake := &fakeIndexServer{existing: map[string]bool{"deliveries": true}, created: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	if err := CreateWebhookIndices(context.Background(), newFakeClient(t, server), "webhooks", "deliveries"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.fieldType("webhooks", DocumentTypeSubscription, "user_id"); got != "keyword" {
+		t.Errorf("expected user_id to be a keyword, got %v", got)
+	}
+	if _, ok := fake.created["deliveries"]; ok {
+		t.Error("expected the existing index to be left as is")
+	}
+}
+
+func TestCreateRuleIndexMapsTheUserAsKeyword(t *testing.T) {
+	fake := &fakeIndexServer{existing: map[string]bool{}, created: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	if err := CreateRuleIndex(context.Background(), newFakeClient(t, server), "rules"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.fieldType("rules", DocumentTypeRule, "user_id"); got != "keyword" {
+		t.Errorf("expected user_id to be a keyword, got %v", got)
+	}
+}
+
+func TestCreateDuplicateIndexMapsTheUserAndActionAsKeywords(t *testing.T) {
+	fake := &fakeIndexServer{existing: map[string]bool{}, created: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	if err := CreateDuplicateIndex(context.Background(), newFakeClient(t, server), "resolutions"); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"user_id", "action"} {
+		if got := fake.fieldType("resolutions", DocumentTypeResolution, field); got != "keyword" {
+			t.Errorf("expected %s to be a keyword, got %v", field, got)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package elastic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+
+	elasticapi "gopkg.in/olivere/elastic.v5"
+)
+
+// matchDocument evaluates the JSON of a query against a stored document, the way ElasticSearch does for the
+// queries built by this package: bool, term, terms, exists, range, match_phrase, match_all and match_none. The
+// string fields missing from the mapping of the index are analyzed by the standard analyzer, their value as is
+// being in their keyword sub-field, the others are keywords. It lets the tests check that a query keeps the documents it should,
+// without a running ElasticSearch.
+func matchDocument(t *testing.T, query elasticapi.Query, document string) bool {
+	t.Helper()
+
+	source, err := query.Source()
+	if err != nil {
+		t.Fatalf("query source: %v", err)
+	}
+	raw, err := json.Marshal(source)
+	if err != nil {
+		t.Fatalf("query json: %v", err)
+	}
+	var q map[string]interface{}
+	if err := json.Unmarshal(raw, &q); err != nil {
+		t.Fatalf("query json: %v", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(document), &doc); err != nil {
+		t.Fatalf("document json: %v", err)
+	}
+
+	matched, err := evaluate(q, doc)
+	if err != nil {
+		t.Fatalf("evaluate %s: %v", raw, err)
+	}
+	return matched
+}
+
+func evaluate(q map[string]interface{}, doc map[string]interface{}) (bool, error) {
+	if len(q) != 1 {
+		return false, fmt.Errorf("expected a single query kind, got %v", q)
+	}
+	for kind, body := range q {
+		switch kind {
+		case "match_all":
+			return true, nil
+		case "match_none":
+			return false, nil
+		case "match_phrase":
+			for field, value := range body.(map[string]interface{}) {
+				if m, ok := value.(map[string]interface{}); ok {
+					value = m["query"]
+				}
+				phrase := analyze(fmt.Sprint(value))
+				for _, v := range lookup(doc, field) {
+					if containsPhrase(analyze(fmt.Sprint(v)), phrase) {
+						return true, nil
+					}
+				}
+				return false, nil
+			}
+		case "bool":
+			return evaluateBool(body.(map[string]interface{}), doc)
+		case "term":
+			for field, value := range body.(map[string]interface{}) {
+				if m, ok := value.(map[string]interface{}); ok {
+					value = m["value"]
+				}
+				return containsValue(indexed(doc, field), value), nil
+			}
+		case "terms":
+			for field, values := range body.(map[string]interface{}) {
+				for _, value := range values.([]interface{}) {
+					if containsValue(indexed(doc, field), value) {
+						return true, nil
+					}
+				}
+				return false, nil
+			}
+		case "exists":
+			field := body.(map[string]interface{})["field"].(string)
+			values := lookup(doc, field)
+			return len(values) > 0, nil
+		case "range":
+			for field, bounds := range body.(map[string]interface{}) {
+				return evaluateRange(lookup(doc, field), bounds.(map[string]interface{}))
+			}
+		}
+		return false, fmt.Errorf("unsupported query %q", kind)
+	}
+	return false, nil
+}
+
+// clauses reads a bool clause, which is a single query or a list of queries
+func clauses(value interface{}) []map[string]interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{v}
+	case []interface{}:
+		result := make([]map[string]interface{}, len(v))
+		for i, item := range v {
+			result[i] = item.(map[string]interface{})
+		}
+		return result
+	}
+	return nil
+}
+
+func evaluateBool(body map[string]interface{}, doc map[string]interface{}) (bool, error) {
+	for _, kind := range []string{"must", "filter"} {
+		for _, clause := range clauses(body[kind]) {
+			matched, err := evaluate(clause, doc)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+	}
+	for _, clause := range clauses(body["must_not"]) {
+		matched, err := evaluate(clause, doc)
+		if err != nil || matched {
+			return false, err
+		}
+	}
+
+	should := clauses(body["should"])
+	if len(should) == 0 {
+		return true, nil
+	}
+	// without must nor filter, one should clause at least has to match
+	minimum := 0
+	if body["must"] == nil && body["filter"] == nil {
+		minimum = 1
+	}
+	if m, ok := body["minimum_should_match"]; ok {
+		fmt.Sscan(fmt.Sprint(m), &minimum)
+	}
+	count := 0
+	for _, clause := range should {
+		matched, err := evaluate(clause, doc)
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			count++
+		}
+	}
+	return count >= minimum, nil
+}
+
+func evaluateRange(values []interface{}, bounds map[string]interface{}) (bool, error) {
+	includeLower, includeUpper := true, true
+	if v, ok := bounds["include_lower"].(bool); ok {
+		includeLower = v
+	}
+	if v, ok := bounds["include_upper"].(bool); ok {
+		includeUpper = v
+	}
+	for _, value := range values {
+		if inRange(value, bounds["from"], bounds["to"], includeLower, includeUpper) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func inRange(value, from, to interface{}, includeLower, includeUpper bool) bool {
+	if from != nil {
+		c := compareValues(value, from)
+		if c < 0 || (c == 0 && !includeLower) {
+			return false
+		}
+	}
+	if to != nil {
+		c := compareValues(value, to)
+		if c > 0 || (c == 0 && !includeUpper) {
+			return false
+		}
+	}
+	return true
+}
+
+// compareValues compares numbers as numbers, and dates or strings as dates when both parse
+func compareValues(a, b interface{}) int {
+	if fa, ok := a.(float64); ok {
+		if fb, ok := b.(float64); ok {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+	sa, sb := fmt.Sprint(a), fmt.Sprint(b)
+	ta, errA := time.Parse(time.RFC3339Nano, sa)
+	tb, errB := time.Parse(time.RFC3339Nano, sb)
+	if errA == nil && errB == nil {
+		switch {
+		case ta.Before(tb):
+			return -1
+		case ta.After(tb):
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(sa, sb)
+}
+
+// analyzedFields are the fields the dynamic mapping makes analyzed text: the ones of the transactions index
+// mapping are keywords, those added later are not
+var analyzedFields = map[string]bool{
+	"description":       true,
+	"counterparty.name": true,
+	"reference":         true,
+	"tags":              true,
+	"category":          true,
+}
+
+// indexed returns the terms indexed for a field: the words of an analyzed field, the values of the others
+func indexed(doc map[string]interface{}, field string) []interface{} {
+	if strings.HasSuffix(field, ".keyword") {
+		return lookup(doc, strings.TrimSuffix(field, ".keyword"))
+	}
+	values := lookup(doc, field)
+	if !analyzedFields[field] {
+		return values
+	}
+	var terms []interface{}
+	for _, value := range values {
+		for _, word := range analyze(fmt.Sprint(value)) {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// analyze splits a text into lower case words, like the standard analyzer
+func analyze(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// containsPhrase tells whether the words of the phrase appear in sequence
+func containsPhrase(words, phrase []string) bool {
+	if len(phrase) == 0 {
+		return false
+	}
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		if strings.Join(words[i:i+len(phrase)], " ") == strings.Join(phrase, " ") {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the values of a dotted field, an array holding several values
+func lookup(doc map[string]interface{}, field string) []interface{} {
+	var current interface{} = doc
+	for _, part := range strings.Split(field, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[part]
+	}
+	switch v := current.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	}
+	return []interface{}{current}
+}
+
+func containsValue(values []interface{}, expected interface{}) bool {
+	for _, value := range values {
+		if fmt.Sprint(value) == fmt.Sprint(expected) {
+			return true
+		}
+	}
+	return false
+}
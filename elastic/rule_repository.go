@@ -0,0 +1,133 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+
+	apierror "github.com/fsilberstein/parameters-issue/errors"
+	"github.com/fsilberstein/parameters-issue/rules"
+	"github.com/pkg/errors"
+	elasticapi "gopkg.in/olivere/elastic.v5"
+)
+
+const (
+	DocumentTypeRule = "rule"
+
+	// maxRules is the most rules read for a user, above what a user can create
+	maxRules = 500
+)
+
+type ruleRepository struct {
+	IndexName     string
+	elasticClient *elasticapi.Client
+}
+
+// NewRuleRepository ...
+func NewRuleRepository(indexName string, elasticClient *elasticapi.Client) rules.Repository {
+	return &ruleRepository{
+		IndexName:     indexName,
+		elasticClient: elasticClient,
+	}
+}
+
+// CreateRuleIndex creates the index of the rules with its mapping, the rules being read by user
+func CreateRuleIndex(ctx context.Context, elasticClient *elasticapi.Client, indexName string) error {
+	return createIndex(ctx, elasticClient, indexName, DocumentTypeRule, keywords("id", "user_id"))
+}
+
+func (repo *ruleRepository) Create(ctx context.Context, rule *rules.Rule) error {
+	if repo.elasticClient == nil {
+		return ErrElasticSearchNotReachable
+	}
+
+	_, err := repo.elasticClient.Index().
+		Index(repo.IndexName).
+		Type(DocumentTypeRule).
+		Id(rule.ID).
+		OpType("create").
+		BodyJson(rule).
+		Refresh("wait_for"). // the next listing is categorized with the rule
+		Do(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error during elastic index")
+	}
+	return nil
+}
+
+func (repo *ruleRepository) Get(ctx context.Context, ruleID string) (*rules.Rule, error) {
+	if repo.elasticClient == nil {
+		return nil, ErrElasticSearchNotReachable
+	}
+
+	getResult, err := repo.elasticClient.Get().
+		Index(repo.IndexName).
+		Type(DocumentTypeRule).
+		Id(ruleID).
+		Do(ctx)
+	if elasticapi.IsNotFound(err) || (err == nil && (!getResult.Found || getResult.Source == nil)) {
+		return nil, apierror.NewNotFoundError("rule")
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error during elastic get")
+	}
+
+	var rule rules.Rule
+	if err := json.Unmarshal(*getResult.Source, &rule); err != nil {
+		return nil, errors.Wrap(err, "malformed rule document")
+	}
+	rule.ID = getResult.Id
+	return &rule, nil
+}
+
+func (repo *ruleRepository) GetByUser(ctx context.Context, userID string) ([]*rules.Rule, error) {
+	if repo.elasticClient == nil {
+		return nil, ErrElasticSearchNotReachable
+	}
+
+	searchResult, err := repo.elasticClient.Search(repo.IndexName).
+		Index(repo.IndexName).
+		Type(DocumentTypeRule).
+		Query(elasticapi.NewTermQuery("user_id", userID)).
+		Size(maxRules).
+		Do(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error during elastic search")
+	}
+
+	list := []*rules.Rule{}
+	if searchResult.Hits == nil {
+		return list, nil
+	}
+	for _, hit := range searchResult.Hits.Hits {
+		if hit.Source == nil {
+			continue
+		}
+		var rule rules.Rule
+		if err := json.Unmarshal(*hit.Source, &rule); err != nil {
+			return nil, errors.Wrap(err, "malformed rule document")
+		}
+		rule.ID = hit.Id
+		list = append(list, &rule)
+	}
+	return list, nil
+}
+
+func (repo *ruleRepository) Delete(ctx context.Context, ruleID string) error {
+	if repo.elasticClient == nil {
+		return ErrElasticSearchNotReachable
+	}
+
+	_, err := repo.elasticClient.Delete().
+		Index(repo.IndexName).
+		Type(DocumentTypeRule).
+		Id(ruleID).
+		Refresh("wait_for").
+		Do(ctx)
+	if elasticapi.IsNotFound(err) {
+		return apierror.NewNotFoundError("rule")
+	}
+	if err != nil {
+		return errors.Wrap(err, "error during elastic delete")
+	}
+	return nil
+}
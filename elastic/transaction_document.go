@@ -0,0 +1,213 @@
+package elastic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/transactions"
+	elasticapi "gopkg.in/olivere/elastic.v5"
+)
+
+// transactionDocument is the representation of a transaction as stored in ElasticSearch.
+// Mandatory fields are pointers so that a missing field can be told apart from a zero value.
+type transactionDocument struct {
+	UserID          *string               `json:"user_id"`
+	Type            string                `json:"type"`
+	Status          string                `json:"status"`
+	Amount          *float64              `json:"amount"`
+	Currency        *string               `json:"currency"`
+	Counterparty    *counterpartyDocument `json:"counterparty"`
+	Description     string                `json:"description"`
+	Reference       string                `json:"reference"`
+	CreationDate    *time.Time            `json:"creation_date"`
+	DueDate         *time.Time            `json:"due_date"`
+	LinkedDocuments []linkDocument        `json:"linked_documents"`
+	UpdatedAt       *time.Time            `json:"updated_at"`
+	// LastEventAt is written even when empty, so that a write outside the events consumer clears it
+	LastEventAt *time.Time `json:"last_event_at"`
+	// Fingerprint identifies the payload of the API call which created the transaction
+	Fingerprint string `json:"idempotency_fingerprint,omitempty"`
+	// the annotations of the user are omitted when empty, so that an upsert of the transaction keeps them
+	Tags     []string `json:"tags,omitempty"`
+	Note     string   `json:"note,omitempty"`
+	Category string   `json:"category,omitempty"`
+	// DuplicateOf is set once the transaction is merged into another one
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+}
+
+type counterpartyDocument struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	IBAN string `json:"iban"`
+}
+
+type linkDocument struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// MalformedDocumentError is returned when a document stored in ElasticSearch can not be mapped to a Transaction.
+// UserID is the owner of the document, empty when it can not be read either.
+type MalformedDocumentError struct {
+	ID     string
+	UserID string
+	Reason string
+}
+
+func (e MalformedDocumentError) Error() string {
+	return fmt.Sprintf("malformed transaction document '%s': %s", e.ID, e.Reason)
+}
+
+// Owner implements transactions.OwnedError
+func (e MalformedDocumentError) Owner() string {
+	return e.UserID
+}
+
+// toTransaction maps a search hit to a Transaction, reporting any missing or invalid field
+func toTransaction(hit *elasticapi.SearchHit) (*transactions.Transaction, error) {
+	if hit.Source == nil {
+		return nil, MalformedDocumentError{ID: hit.Id, Reason: "empty source"}
+	}
+
+	transaction, err := decodeTransaction(hit.Id, *hit.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	if hit.Version != nil {
+		transaction.Version = *hit.Version
+	}
+	if len(hit.Highlight) > 0 {
+		transaction.Highlights = hit.Highlight
+	}
+	return transaction, nil
+}
+
+// toTransactions maps every hit of a search result, it stops at the first malformed document
+func toTransactions(searchResult *elasticapi.SearchResult) ([]*transactions.Transaction, error) {
+	if searchResult.Hits == nil {
+		return nil, nil
+	}
+
+	result := make([]*transactions.Transaction, 0, len(searchResult.Hits.Hits))
+	for _, hit := range searchResult.Hits.Hits {
+		transaction, err := toTransaction(hit)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, transaction)
+	}
+	return result, nil
+}
+
+func decodeTransaction(id string, source json.RawMessage) (*transactions.Transaction, error) {
+	var doc transactionDocument
+	if err := json.Unmarshal(source, &doc); err != nil {
+		// the owner is read alone, the error being about another field most of the time
+		var owner struct {
+			UserID string `json:"user_id"`
+		}
+		json.Unmarshal(source, &owner)
+		return nil, MalformedDocumentError{ID: id, UserID: owner.UserID, Reason: err.Error()}
+	}
+
+	var owner string
+	if doc.UserID != nil {
+		owner = *doc.UserID
+	}
+
+	var missing []string
+	if doc.UserID == nil {
+		missing = append(missing, "user_id")
+	}
+	if doc.Amount == nil {
+		missing = append(missing, "amount")
+	}
+	if doc.Currency == nil {
+		missing = append(missing, "currency")
+	}
+	if doc.CreationDate == nil {
+		missing = append(missing, "creation_date")
+	}
+	if len(missing) > 0 {
+		return nil, MalformedDocumentError{ID: id, UserID: owner, Reason: "missing " + strings.Join(missing, ", ")}
+	}
+
+	status := transactions.Status(doc.Status)
+	if doc.Status != "" && !status.IsValid() {
+		return nil, MalformedDocumentError{ID: id, UserID: owner, Reason: fmt.Sprintf("unknown status '%s'", doc.Status)}
+	}
+
+	transaction := &transactions.Transaction{
+		ID:           id,
+		UserID:       *doc.UserID,
+		Type:         doc.Type,
+		Status:       status,
+		Amount:       *doc.Amount,
+		Currency:     *doc.Currency,
+		Description:  doc.Description,
+		Reference:    doc.Reference,
+		CreationDate: *doc.CreationDate,
+		DueDate:      doc.DueDate,
+		UpdatedAt:    doc.UpdatedAt,
+		LastEventAt:  doc.LastEventAt,
+		Tags:         doc.Tags,
+		Note:         doc.Note,
+		Category:     doc.Category,
+		DuplicateOf:  doc.DuplicateOf,
+
+		IdempotencyFingerprint: doc.Fingerprint,
+	}
+
+	if doc.Counterparty != nil {
+		transaction.Counterparty = &transactions.Counterparty{
+			ID:   doc.Counterparty.ID,
+			Name: doc.Counterparty.Name,
+			IBAN: doc.Counterparty.IBAN,
+		}
+	}
+
+	for _, link := range doc.LinkedDocuments {
+		if link.Type == "" || link.ID == "" {
+			return nil, MalformedDocumentError{ID: id, UserID: owner, Reason: "incomplete linked document"}
+		}
+		transaction.LinkedDocuments = append(transaction.LinkedDocuments, &transactions.DocumentLink{Type: link.Type, ID: link.ID})
+	}
+
+	return transaction, nil
+}
+
+// toDocument is the reverse of decodeTransaction, the ID of the transaction is the ID of the document
+func toDocument(t *transactions.Transaction) transactionDocument {
+	doc := transactionDocument{
+		UserID:       &t.UserID,
+		Type:         t.Type,
+		Status:       string(t.Status),
+		Amount:       &t.Amount,
+		Currency:     &t.Currency,
+		Description:  t.Description,
+		Reference:    t.Reference,
+		CreationDate: &t.CreationDate,
+		DueDate:      t.DueDate,
+		UpdatedAt:    t.UpdatedAt,
+		LastEventAt:  t.LastEventAt,
+		Fingerprint:  t.IdempotencyFingerprint,
+		DuplicateOf:  t.DuplicateOf,
+	}
+
+	if t.Counterparty != nil {
+		doc.Counterparty = &counterpartyDocument{
+			ID:   t.Counterparty.ID,
+			Name: t.Counterparty.Name,
+			IBAN: t.Counterparty.IBAN,
+		}
+	}
+
+	for _, link := range t.LinkedDocuments {
+		doc.LinkedDocuments = append(doc.LinkedDocuments, linkDocument{Type: link.Type, ID: link.ID})
+	}
+
+	return doc
+}
@@ -0,0 +1,164 @@
+package elastic
+
+import (
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/transactions"
+	elasticapi "gopkg.in/olivere/elastic.v5"
+)
+
+// buildQuery translates the filters of a TransactionQuery into an ElasticSearch query.
+// Sort and pagination are applied by the caller on the search service.
+func buildQuery(query transactions.TransactionQuery) *elasticapi.BoolQuery {
+	var musts []elasticapi.Query
+
+	// Scope query to a user
+	if query.UserID != nil {
+		musts = append(musts, elasticapi.NewTermQuery("user_id", *query.UserID))
+	}
+
+	typeQuery := getTypeQuery(query.Type)
+	if typeQuery != nil {
+		musts = append(musts, typeQuery)
+	}
+
+	statusQuery := getStatusQuery(query.Status)
+	if statusQuery != nil {
+		musts = append(musts, statusQuery)
+	}
+
+	amountQuery := getAmountQuery(query.AmountMin, query.AmountMax)
+	if amountQuery != nil {
+		musts = append(musts, amountQuery)
+	}
+
+	if len(query.Currency) > 0 {
+		musts = append(musts, elasticapi.NewTermsQuery("currency", toInterfaces(query.Currency)...))
+	}
+
+	if len(query.Tag) > 0 {
+		musts = append(musts, elasticapi.NewTermsQuery(keywordField("tags"), toInterfaces(query.Tag)...))
+	}
+
+	textQuery := getTextQuery(query.Text, query.Fuzzy)
+	if textQuery != nil {
+		musts = append(musts, textQuery)
+	}
+
+	if query.Filter != nil {
+		musts = append(musts, getFilterQuery(query.Filter))
+	}
+
+	dateRangeQuery := getRangeQuery(query.DateFrom, query.DateTo)
+	if dateRangeQuery != nil {
+		musts = append(musts, dateRangeQuery)
+	}
+
+	boolQuery := elasticapi.NewBoolQuery().Must(musts...)
+	if query.HideDuplicates {
+		boolQuery = boolQuery.MustNot(elasticapi.NewExistsQuery("duplicate_of"))
+	}
+	if excludedQuery := getStatusQuery(query.ExcludedStatus); excludedQuery != nil {
+		// a transaction without status is kept, unlike with a terms query on the other statuses
+		boolQuery = boolQuery.MustNot(excludedQuery)
+	}
+	return boolQuery
+}
+
+// getTypeQuery matches the transactions of one of the types, stored in the `type` field
+func getTypeQuery(types []string) *elasticapi.TermsQuery {
+	if len(types) > 0 {
+		return elasticapi.NewTermsQuery("type", toInterfaces(types)...)
+	}
+	return nil
+}
+
+func getStatusQuery(statuses []transactions.Status) *elasticapi.TermsQuery {
+	if len(statuses) > 0 {
+		values := make([]interface{}, len(statuses))
+		for i, status := range statuses {
+			values[i] = string(status)
+		}
+
+		return elasticapi.NewTermsQuery("status", values...)
+	}
+	return nil
+}
+
+// getAmountQuery builds an inclusive range on the amount
+func getAmountQuery(amountMin, amountMax *float64) *elasticapi.RangeQuery {
+	if amountMin != nil || amountMax != nil {
+		amountRangeQuery := elasticapi.NewRangeQuery("amount")
+		if amountMin != nil {
+			amountRangeQuery.Gte(*amountMin)
+		}
+		if amountMax != nil {
+			amountRangeQuery.Lte(*amountMax)
+		}
+		return amountRangeQuery
+	}
+	return nil
+}
+
+// textSearchFields are the fields searched by a full-text query, they are also the highlighted ones
+var textSearchFields = []string{"description", "counterparty.name", "reference"}
+
+// annotationFields are the fields set by the users, added after the transactions were mapped: the dynamic mapping
+// makes them analyzed text too
+var annotationFields = []string{"tags", "category"}
+
+// keywordField returns the field to match a whole value on. The analyzed fields hold their value as is in the
+// keyword sub-field the dynamic mapping adds to them, a tag like "VAT exempt" being two words otherwise.
+func keywordField(field string) string {
+	for _, fields := range [][]string{textSearchFields, annotationFields} {
+		for _, text := range fields {
+			if field == text {
+				return field + ".keyword"
+			}
+		}
+	}
+	return field
+}
+
+func getTextQuery(text string, fuzzy bool) *elasticapi.MultiMatchQuery {
+	if text == "" {
+		return nil
+	}
+
+	textQuery := elasticapi.NewMultiMatchQuery(text, textSearchFields...)
+	if fuzzy {
+		textQuery.Fuzziness("AUTO")
+	}
+	return textQuery
+}
+
+// getHighlight asks ElasticSearch for the fragments of the searched fields which matched the query
+func getHighlight() *elasticapi.Highlight {
+	fields := make([]*elasticapi.HighlighterField, len(textSearchFields))
+	for i, field := range textSearchFields {
+		fields[i] = elasticapi.NewHighlighterField(field)
+	}
+	return elasticapi.NewHighlight().Fields(fields...)
+}
+
+func getRangeQuery(dateFrom, dateTo *time.Time) *elasticapi.RangeQuery {
+	if dateFrom != nil || dateTo != nil {
+		dateRangeQuery := elasticapi.NewRangeQuery("creation_date").IncludeUpper(false).IncludeLower(true)
+		if dateFrom != nil {
+			dateRangeQuery.From(*dateFrom)
+		}
+		if dateTo != nil {
+			dateRangeQuery.To(*dateTo)
+		}
+		return dateRangeQuery
+	}
+	return nil
+}
+
+func toInterfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, value := range values {
+		result[i] = value
+	}
+	return result
+}
@@ -0,0 +1,75 @@
+package elastic
+
+import (
+	"testing"
+
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+const storedFee = `{"user_id":"u1","type":"fee","status":"paid","amount":12.5,"currency":"EUR","creation_date":"2026-01-10T10:00:00Z"}`
+
+func TestBuildQueryFiltersByStoredType(t *testing.T) {
+	userID := "u1"
+	tests := []struct {
+		name  string
+		types []string
+		want  bool
+	}{
+		{"same type", []string{"fee"}, true},
+		{"one of the types", []string{"invoice", "fee"}, true},
+		{"other type", []string{"invoice"}, false},
+		{"no type filter", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := transactions.NewTransactionQuery()
+			query.UserID = &userID
+			query.Type = tt.types
+
+			if got := matchDocument(t, buildQuery(query), storedFee); got != tt.want {
+				t.Errorf("type %v matched the stored fee: %v, want %v", tt.types, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildQueryFiltersByWholeTag(t *testing.T) {
+	const stored = `{"user_id":"u1","type":"fee","status":"paid","amount":12.5,"currency":"EUR","creation_date":"2026-01-10T10:00:00Z","tags":["VAT exempt","Rent"]}`
+	userID := "u1"
+	tests := []struct {
+		tags []string
+		want bool
+	}{
+		{[]string{"VAT exempt"}, true},
+		{[]string{"Travel", "Rent"}, true},
+		{[]string{"vat exempt"}, false},
+		{[]string{"VAT"}, false},
+	}
+
+	for _, tt := range tests {
+		query := transactions.NewTransactionQuery()
+		query.UserID = &userID
+		query.Tag = tt.tags
+
+		if got := matchDocument(t, buildQuery(query), stored); got != tt.want {
+			t.Errorf("tags %q matched the stored fee: %v, want %v", tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestBuildQueryExcludedStatusKeepsTransactionsWithoutStatus(t *testing.T) {
+	query := transactions.NewTransactionQuery()
+	query.ExcludedStatus = []transactions.Status{transactions.StatusCancelled}
+
+	documents := map[string]bool{
+		storedFee: true,
+		`{"user_id":"u1","type":"fee","amount":3,"currency":"EUR","creation_date":"2026-01-10T10:00:00Z"}`:                      true,
+		`{"user_id":"u1","type":"fee","status":"cancelled","amount":3,"currency":"EUR","creation_date":"2026-01-10T10:00:00Z"}`: false,
+	}
+	for document, want := range documents {
+		if got := matchDocument(t, buildQuery(query), document); got != want {
+			t.Errorf("matched %s: %v, want %v", document, got, want)
+		}
+	}
+}
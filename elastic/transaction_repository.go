@@ -2,12 +2,15 @@ package elastic
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
 	"time"
 
 	"github.com/fsilberstein/parameters-issue/config"
 	apierror "github.com/fsilberstein/parameters-issue/errors"
+	"github.com/fsilberstein/parameters-issue/logger"
 	"github.com/fsilberstein/parameters-issue/transactions"
 	"github.com/pkg/errors"
 	elasticapi "gopkg.in/olivere/elastic.v5"
@@ -46,118 +49,484 @@ func getFromAndSize(pageSize int, page int) (int, int, error) {
 }
 
 // GetTransactions ...
-func (repo *transactionRepository) GetByUser(ctx context.Context, userID string, transactionType []string, sort string, page, pageSize int, dateFrom, dateTo *time.Time, open *bool) (result []*transactions.Transaction, total int64, err error) {
+func (repo *transactionRepository) GetByUser(ctx context.Context, query transactions.TransactionQuery) (*transactions.TransactionPage, error) {
 	if repo.elasticClient == nil {
-		err = ErrElasticSearchNotReachable
-		return
+		return nil, ErrElasticSearchNotReachable
 	}
 
-	// Scope query to a user
-	musts := []elasticapi.Query{elasticapi.NewTermQuery("user_id", userID)}
+	from, size, err := getFromAndSize(query.PageSize, query.Page)
+	if err != nil {
+		return nil, err
+	}
+
+	searchService := repo.elasticClient.Search(repo.IndexName).
+		Index(repo.IndexName). // search in index
+		Type(DocumentTypeTransaction).
+		Query(buildQuery(query)). // specify the query
+		SortBy(getSort(query.Sort)...).
+		Version(true). // the version is expected by updates
+		Size(size)
 
-	typeQuery := getTypeQuery(transactionType)
-	if typeQuery != nil {
-		musts = append(musts, typeQuery)
+	if query.Text != "" {
+		searchService = searchService.Highlight(getHighlight())
 	}
 
-	dateRangeQuery := getRangeQuery(dateFrom, dateTo)
-	if dateRangeQuery != nil {
-		musts = append(musts, dateRangeQuery)
+	// a cursor replaces the offset, ElasticSearch resumes right after the sort values it holds
+	if query.Cursor != nil {
+		searchService = searchService.SearchAfter(query.Cursor.Values...)
+	} else {
+		searchService = searchService.From(from)
 	}
 
-	query := elasticapi.NewBoolQuery().Must(musts...)
+	// do request to ElasticSearch
+	searchResult, err := searchService.Do(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error during elastic search")
+	}
 
-	from, size, err := getFromAndSize(pageSize, page)
+	result, err := toTransactions(searchResult)
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	sortObj := getSort(sort)
-	searchService := repo.elasticClient.Search(repo.IndexName).
-		Index(repo.IndexName). // search in index
+	return &transactions.TransactionPage{
+		Transactions: result,
+		Total:        searchResult.TotalHits(),
+		NextCursor:   getNextCursor(searchResult, query.Sort, size),
+	}, nil
+}
+
+// getNextCursor builds a cursor from the last hit of a full page, a page with less hits than requested is the last one
+func getNextCursor(searchResult *elasticapi.SearchResult, sort transactions.SortSpec, size int) *transactions.Cursor {
+	if searchResult.Hits == nil || len(searchResult.Hits.Hits) == 0 || len(searchResult.Hits.Hits) < size {
+		return nil
+	}
+
+	last := searchResult.Hits.Hits[len(searchResult.Hits.Hits)-1]
+	return &transactions.Cursor{Sort: sort.String(), Values: last.Sort}
+}
+
+// GetByDateRange gathers every matching transaction in memory, prefer Stream for wide date ranges
+func (repo *transactionRepository) GetByDateRange(ctx context.Context, query transactions.TransactionQuery) (result []*transactions.Transaction, total int64, err error) {
+	err = repo.Stream(ctx, query, func(page []*transactions.Transaction) error {
+		result = append(result, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, int64(0), err
+	}
+
+	total = int64(len(result))
+	return result, total, nil
+}
+
+func (repo *transactionRepository) GetByID(ctx context.Context, transactionID string) (*transactions.Transaction, error) {
+	if repo.elasticClient == nil {
+		return nil, ErrElasticSearchNotReachable
+	}
+
+	getResult, err := repo.elasticClient.Get().
+		Index(repo.IndexName).
 		Type(DocumentTypeTransaction).
-		Query(query). // specify the query
-		SortBy(sortObj).
-		Size(size).
-		From(from)
+		Id(transactionID).
+		Do(ctx)
+	if elasticapi.IsNotFound(err) || (err == nil && !getResult.Found) {
+		return nil, apierror.NewNotFoundError("transaction")
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error during elastic get")
+	}
 
-	// do request to ElasticSearch
-	var searchResult *elasticapi.SearchResult
-	searchResult, err = searchService.Do(ctx)
+	if getResult.Source == nil {
+		return nil, MalformedDocumentError{ID: transactionID, Reason: "empty source"}
+	}
+	transaction, err := decodeTransaction(transactionID, *getResult.Source)
 	if err != nil {
-		return result, total, errors.Wrap(err, "error during elastic search")
+		return nil, err
+	}
+	if getResult.Version != nil {
+		transaction.Version = *getResult.Version
 	}
+	return transaction, nil
+}
 
-	// then here is the where business logic go to map what is in ES and what we return
-	// not relevant here
-	total = searchResult.TotalHits()
+// GetByIDs reads the transactions with a realtime multi get
+func (repo *transactionRepository) GetByIDs(ctx context.Context, transactionIDs []string) ([]*transactions.Transaction, error) {
+	if repo.elasticClient == nil {
+		return nil, ErrElasticSearchNotReachable
+	}
+	list := []*transactions.Transaction{}
+	if len(transactionIDs) == 0 {
+		return list, nil
+	}
 
-	return result, total, nil
+	mget := repo.elasticClient.MultiGet().Realtime(true)
+	for _, id := range transactionIDs {
+		mget = mget.Add(elasticapi.NewMultiGetItem().
+			Index(repo.IndexName).
+			Type(DocumentTypeTransaction).
+			Id(id))
+	}
+	response, err := mget.Do(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error during elastic mget")
+	}
+
+	for _, doc := range response.Docs {
+		if !doc.Found {
+			continue
+		}
+		if doc.Source == nil {
+			return nil, MalformedDocumentError{ID: doc.Id, Reason: "empty source"}
+		}
+		transaction, err := decodeTransaction(doc.Id, *doc.Source)
+		if err != nil {
+			return nil, err
+		}
+		if doc.Version != nil {
+			transaction.Version = *doc.Version
+		}
+		list = append(list, transaction)
+	}
+	return list, nil
 }
 
-func (repo *transactionRepository) GetByDateRange(ctx context.Context, transactionType []string, dateFrom, dateTo *time.Time) (result []*transactions.Transaction, total int64, err error) {
+func (repo *transactionRepository) GetRelated(ctx context.Context, userID string, transactionIDs []string) ([]*transactions.Transaction, error) {
 	if repo.elasticClient == nil {
-		err = ErrElasticSearchNotReachable
-		return
+		return nil, ErrElasticSearchNotReachable
 	}
 
-	var musts []elasticapi.Query
+	ids := toInterfaces(transactionIDs)
+	query := elasticapi.NewBoolQuery().
+		Must(elasticapi.NewTermQuery("user_id", userID)).
+		Should(
+			elasticapi.NewIdsQuery(DocumentTypeTransaction).Ids(transactionIDs...),
+			elasticapi.NewTermsQuery("linked_documents.id", ids...),
+		).
+		MinimumNumberShouldMatch(1)
 
-	typeQuery := getTypeQuery(transactionType)
-	if typeQuery != nil {
-		musts = append(musts, typeQuery)
+	searchResult, err := repo.elasticClient.Search(repo.IndexName).
+		Index(repo.IndexName).
+		Type(DocumentTypeTransaction).
+		Query(query).
+		Size(elasticResponseSize).
+		Do(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error during elastic search")
 	}
 
-	dateRangeQuery := getRangeQuery(dateFrom, dateTo)
-	if dateRangeQuery != nil {
-		musts = append(musts, dateRangeQuery)
+	return toTransactions(searchResult)
+}
+
+// Stream scrolls through the matching transactions, in the order of the sort of the query. The scroll context is
+// cleared on ElasticSearch whatever the outcome, and the scroll stops as soon as ctx is done or fn fails.
+func (repo *transactionRepository) Stream(ctx context.Context, query transactions.TransactionQuery, fn func([]*transactions.Transaction) error) error {
+	if repo.elasticClient == nil {
+		return ErrElasticSearchNotReachable
 	}
 
-	query := elasticapi.NewBoolQuery().Must(musts...)
 	scroll := repo.elasticClient.
 		Scroll(repo.IndexName).
 		Type(DocumentTypeTransaction).
-		Query(query).
+		Query(buildQuery(query)).
+		SortBy(getSort(query.Sort)...).
 		Size(elasticResponseSize)
 
+	defer func() {
+		// ctx may already be cancelled, the scroll context still has to be released
+		clearCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := scroll.Clear(clearCtx); err != nil {
+			logger.LogStdErr.Error(errors.Wrap(err, "could not clear elastic scroll"))
+		}
+	}()
+
 	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		searchResult, err := scroll.Do(ctx)
 		if err == io.EOF {
-			break
+			return nil
 		}
 		if err != nil {
-			return nil, int64(0), err
+			return errors.Wrap(err, "error during elastic scroll")
 		}
 
-		// then here is the where business logic go to map what is in ES and what we return
-		// not relevant here
-		total = searchResult.TotalHits()
+		page, err := toTransactions(searchResult)
+		if err != nil {
+			return err
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
 	}
-	return result, total, nil
 }
 
-func getTypeQuery(types []string) *elasticapi.BoolQuery {
-	if types != nil {
-		var existQueries []elasticapi.Query
-		for _, value := range types {
-			existQueries = append(existQueries, elasticapi.NewExistsQuery(value))
-		}
+// stampUpdate records the date of the write, which the changes are ordered by
+func stampUpdate(transaction *transactions.Transaction, now time.Time) {
+	transaction.UpdatedAt = &now
+}
 
-		return elasticapi.NewBoolQuery().Should(existQueries...)
+// Create indexes the transaction with the "create" operation, so that an existing document is never overwritten
+func (repo *transactionRepository) Create(ctx context.Context, transaction *transactions.Transaction) error {
+	if repo.elasticClient == nil {
+		return ErrElasticSearchNotReachable
+	}
+	stampUpdate(transaction, time.Now())
+
+	_, err := repo.elasticClient.Index().
+		Index(repo.IndexName).
+		Type(DocumentTypeTransaction).
+		Id(transaction.ID).
+		OpType("create").
+		BodyJson(toDocument(transaction)).
+		Refresh("wait_for"). // the transaction can be listed as soon as it is created
+		Do(ctx)
+	if elasticapi.IsConflict(err) {
+		return transactions.ErrTransactionExists
+	}
+	if err != nil {
+		return errors.Wrap(err, "error during elastic index")
 	}
 	return nil
 }
 
-func getRangeQuery(dateFrom, dateTo *time.Time) *elasticapi.RangeQuery {
-	if dateFrom != nil || dateFrom != nil {
-		dateRangeQuery := elasticapi.NewRangeQuery("creation_date").IncludeUpper(false).IncludeLower(true)
-		if dateFrom != nil {
-			dateRangeQuery.From(*dateFrom)
+// Bulk writes the transactions in a single request. A transaction without ID is created with an ID generated by
+// ElasticSearch. A transaction with an ID updates the stored one like Save does, keeping the fields it does not
+// hold (annotations, fingerprint...), and fails when the stored one belongs to another user. A new ID is created
+// rather than upserted, so that a concurrent creation fails instead of being overwritten.
+func (repo *transactionRepository) Bulk(ctx context.Context, list []*transactions.Transaction) ([]*transactions.BulkItemResult, error) {
+	if repo.elasticClient == nil {
+		return nil, ErrElasticSearchNotReachable
+	}
+
+	stored, err := repo.getOwners(ctx, list)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	results := make([]*transactions.BulkItemResult, len(list))
+	// written maps the items of the bulk request to the transactions of the list
+	var written []int
+	bulkService := repo.elasticClient.Bulk().
+		Index(repo.IndexName).
+		Type(DocumentTypeTransaction)
+	for i, transaction := range list {
+		owner, exists := stored[transaction.ID]
+		if exists && owner.userID != transaction.UserID {
+			results[i] = &transactions.BulkItemResult{ID: transaction.ID, Result: transactions.BulkFailed, Error: "the transaction belongs to another user"}
+			continue
+		}
+
+		stampUpdate(transaction, now)
+		var request elasticapi.BulkableRequest
+		switch {
+		case transaction.ID == "":
+			request = elasticapi.NewBulkIndexRequest().OpType("create").Doc(toDocument(transaction))
+		case exists:
+			// the version fails the write if the transaction changed since its owner was checked
+			request = elasticapi.NewBulkUpdateRequest().Id(transaction.ID).Version(owner.version).Doc(toDocument(transaction)).DocAsUpsert(true)
+		default:
+			request = elasticapi.NewBulkIndexRequest().OpType("create").Id(transaction.ID).Doc(toDocument(transaction))
+		}
+		bulkService = bulkService.Add(request)
+		written = append(written, i)
+	}
+	if len(written) == 0 {
+		return results, nil
+	}
+
+	response, err := bulkService.Do(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error during elastic bulk")
+	}
+	if len(response.Items) != len(written) {
+		return nil, fmt.Errorf("elastic bulk returned %d items for %d transactions", len(response.Items), len(written))
+	}
+
+	for n, items := range response.Items {
+		i := written[n]
+		result := &transactions.BulkItemResult{ID: list[i].ID, Result: transactions.BulkFailed}
+		// an item holds a single entry, keyed by the operation
+		var item *elasticapi.BulkResponseItem
+		for _, it := range items {
+			item = it
+		}
+		switch {
+		case item == nil:
+			result.Error = "missing item in the elastic bulk response"
+		case item.Error != nil:
+			result.ID = item.Id
+			result.Error = item.Error.Reason
+		case item.Status == http.StatusCreated:
+			result.ID, result.Result = item.Id, transactions.BulkCreated
+		case item.Status == http.StatusOK:
+			result.ID, result.Result = item.Id, transactions.BulkUpdated
+		default:
+			result.ID = item.Id
+			result.Error = fmt.Sprintf("unexpected status %d", item.Status)
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// owner is the user of a stored transaction, with the version it was read at
+type owner struct {
+	userID  string
+	version int64
+}
+
+// getOwners reads the owners of the stored transactions among the list, by ID
+func (repo *transactionRepository) getOwners(ctx context.Context, list []*transactions.Transaction) (map[string]owner, error) {
+	owners := make(map[string]owner)
+	mget := repo.elasticClient.MultiGet().Realtime(true)
+	count := 0
+	for _, transaction := range list {
+		if transaction.ID == "" {
+			continue
+		}
+		mget = mget.Add(elasticapi.NewMultiGetItem().
+			Index(repo.IndexName).
+			Type(DocumentTypeTransaction).
+			Id(transaction.ID).
+			FetchSource(elasticapi.NewFetchSourceContext(true).Include("user_id")))
+		count++
+	}
+	if count == 0 {
+		return owners, nil
+	}
+
+	response, err := mget.Do(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error during elastic mget")
+	}
+	for _, doc := range response.Docs {
+		if !doc.Found || doc.Source == nil {
+			continue
+		}
+		var source struct {
+			UserID string `json:"user_id"`
 		}
-		if dateTo != nil {
-			dateRangeQuery.To(*dateTo)
+		if err := json.Unmarshal(*doc.Source, &source); err != nil {
+			return nil, MalformedDocumentError{ID: doc.Id, Reason: err.Error()}
 		}
-		return dateRangeQuery
+		o := owner{userID: source.UserID}
+		if doc.Version != nil {
+			o.version = *doc.Version
+		}
+		owners[doc.Id] = o
+	}
+	return owners, nil
+}
+
+// Save upserts the transaction. The fields of the stored document which are not part of the transaction, like
+// the idempotency fingerprint of a transaction created through the API, are kept.
+func (repo *transactionRepository) Save(ctx context.Context, transaction *transactions.Transaction) error {
+	if repo.elasticClient == nil {
+		return ErrElasticSearchNotReachable
+	}
+	stampUpdate(transaction, time.Now())
+
+	_, err := repo.elasticClient.Update().
+		Index(repo.IndexName).
+		Type(DocumentTypeTransaction).
+		Id(transaction.ID).
+		Doc(toDocument(transaction)).
+		DocAsUpsert(true).
+		Do(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error during elastic update")
 	}
 	return nil
 }
+
+// Update writes the patch as a partial document, ElasticSearch rejects it when the document is no longer at the
+// given version. An emptied field is set to null, which removes it from the document.
+func (repo *transactionRepository) Update(ctx context.Context, transactionID string, patch transactions.TransactionPatch, version int64) error {
+	if repo.elasticClient == nil {
+		return ErrElasticSearchNotReachable
+	}
+
+	doc := map[string]interface{}{"updated_at": time.Now().UTC()}
+	if patch.Tags != nil {
+		doc["tags"] = nilIfEmpty(len(*patch.Tags) == 0, *patch.Tags)
+	}
+	if patch.Note != nil {
+		doc["note"] = nilIfEmpty(*patch.Note == "", *patch.Note)
+	}
+	if patch.Category != nil {
+		doc["category"] = nilIfEmpty(*patch.Category == "", *patch.Category)
+	}
+	if patch.DuplicateOf != nil {
+		doc["duplicate_of"] = nilIfEmpty(*patch.DuplicateOf == "", *patch.DuplicateOf)
+	}
+
+	_, err := repo.elasticClient.Update().
+		Index(repo.IndexName).
+		Type(DocumentTypeTransaction).
+		Id(transactionID).
+		Version(version).
+		Doc(doc).
+		Do(ctx)
+	if elasticapi.IsConflict(err) {
+		return apierror.NewConflictError(fmt.Sprintf("the transaction was modified since version %d", version))
+	}
+	if elasticapi.IsNotFound(err) {
+		return apierror.NewNotFoundError("transaction")
+	}
+	if err != nil {
+		return errors.Wrap(err, "error during elastic update")
+	}
+	return nil
+}
+
+func nilIfEmpty(empty bool, value interface{}) interface{} {
+	if empty {
+		return nil
+	}
+	return value
+}
+
+// GetChanges searches the transactions by update date, after the sort values of the cursor
+func (repo *transactionRepository) GetChanges(ctx context.Context, query transactions.TransactionQuery, after *transactions.Cursor, until time.Time, size int) ([]*transactions.Change, error) {
+	if repo.elasticClient == nil {
+		return nil, ErrElasticSearchNotReachable
+	}
+
+	changesSort := transactions.ChangesSort()
+
+	searchService := repo.elasticClient.Search(repo.IndexName).
+		Index(repo.IndexName).
+		Type(DocumentTypeTransaction).
+		Query(buildQuery(query).Filter(elasticapi.NewRangeQuery("updated_at").Lt(until))).
+		SortBy(getSort(changesSort)...).
+		Size(size)
+	if after != nil {
+		searchService = searchService.SearchAfter(after.Values...)
+	}
+
+	searchResult, err := searchService.Do(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error during elastic search")
+	}
+	if searchResult.Hits == nil {
+		return nil, nil
+	}
+
+	changes := make([]*transactions.Change, 0, len(searchResult.Hits.Hits))
+	for _, hit := range searchResult.Hits.Hits {
+		transaction, err := toTransaction(hit)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, &transactions.Change{
+			Transaction: transaction,
+			Cursor:      &transactions.Cursor{Sort: changesSort.String(), Values: hit.Sort},
+		})
+	}
+	return changes, nil
+}
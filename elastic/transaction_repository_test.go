@@ -0,0 +1,197 @@
+package elastic
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/transactions"
+	elasticapi "gopkg.in/olivere/elastic.v5"
+)
+
+// fakeBulkServer answers the multi get and bulk requests of the client from a map of stored owners by ID, and
+// records the bulk actions it received
+type fakeBulkServer struct {
+	mu      sync.Mutex
+	owners  map[string]string
+	actions []map[string]map[string]interface{}
+	sources []map[string]interface{}
+}
+
+func (f *fakeBulkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/_mget"):
+		var body struct {
+			Docs []struct {
+				ID string `json:"_id"`
+			} `json:"docs"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		docs := []map[string]interface{}{}
+		for _, doc := range body.Docs {
+			owner, found := f.owners[doc.ID]
+			result := map[string]interface{}{"_index": "transactions", "_type": DocumentTypeTransaction, "_id": doc.ID, "found": found}
+			if found {
+				result["_version"] = 3
+				result["_source"] = map[string]string{"user_id": owner}
+			}
+			docs = append(docs, result)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"docs": docs})
+	case strings.HasSuffix(r.URL.Path, "/_bulk"):
+		var items []map[string]interface{}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var action map[string]map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			scanner.Scan()
+			var source map[string]interface{}
+			json.Unmarshal(scanner.Bytes(), &source)
+			f.actions = append(f.actions, action)
+			f.sources = append(f.sources, source)
+
+			for op, meta := range action {
+				id, _ := meta["_id"].(string)
+				status := http.StatusCreated
+				if op == "update" {
+					status = http.StatusOK
+				}
+				if id == "" {
+					id = fmt.Sprintf("generated-%d", len(items))
+				}
+				items = append(items, map[string]interface{}{op: map[string]interface{}{"_id": id, "status": status}})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"took": 1, "items": items})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newFakeClient returns a client of the server, which is closed by the caller
+func newFakeClient(t *testing.T, server *httptest.Server) *elasticapi.Client {
+	client, err := elasticapi.NewClient(elasticapi.SetURL(server.URL), elasticapi.SetSniff(false), elasticapi.SetHealthcheck(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestBulkUpsertsOwnTransactionsOnly(t *testing.T) {
+	fake := &fakeBulkServer{owners: map[string]string{"mine": "user-1", "theirs": "user-2"}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	repo := NewTransactionRepository("transactions", newFakeClient(t, server))
+
+	now := time.Now()
+	list := []*transactions.Transaction{
+		{ID: "mine", UserID: "user-1", Type: transactions.TypeFee, Status: transactions.StatusPaid, Currency: "EUR", CreationDate: now},
+		{ID: "theirs", UserID: "user-1", Type: transactions.TypeFee, Status: transactions.StatusPaid, Currency: "EUR", CreationDate: now},
+		{ID: "new", UserID: "user-1", Type: transactions.TypeFee, Status: transactions.StatusPaid, Currency: "EUR", CreationDate: now},
+		{UserID: "user-1", Type: transactions.TypeFee, Status: transactions.StatusPaid, Currency: "EUR", CreationDate: now},
+	}
+	results, err := repo.Bulk(context.Background(), list)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct{ id, result string }{
+		{"mine", transactions.BulkUpdated},
+		{"theirs", transactions.BulkFailed},
+		{"new", transactions.BulkCreated},
+		{"generated-2", transactions.BulkCreated},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(results))
+	}
+	for i, e := range expected {
+		if results[i].ID != e.id || results[i].Result != e.result {
+			t.Errorf("result %d: expected %s %s, got %s %s (%s)", i, e.id, e.result, results[i].ID, results[i].Result, results[i].Error)
+		}
+	}
+
+	// the transaction of the other user is never written
+	if len(fake.actions) != 3 {
+		t.Fatalf("expected 3 bulk actions, got %d", len(fake.actions))
+	}
+	update, ok := fake.actions[0]["update"]
+	if !ok {
+		t.Fatalf("expected an update of the stored transaction, got %v", fake.actions[0])
+	}
+	if update["_version"] != float64(3) {
+		t.Errorf("expected the update to be conditioned on the version read, got %v", update["_version"])
+	}
+	if fake.sources[0]["doc_as_upsert"] != true || fake.sources[0]["doc"] == nil {
+		t.Errorf("expected a partial upsert of the document, got %v", fake.sources[0])
+	}
+	for _, action := range fake.actions[1:] {
+		if _, ok := action["create"]; !ok {
+			t.Errorf("expected a creation of the new transactions, got %v", action)
+		}
+	}
+}
+
+func TestGetByIDsReadsInRealtime(t *testing.T) {
+	var realtime string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		realtime = r.URL.Query().Get("realtime")
+		json.NewEncoder(w).Encode(map[string]interface{}{"docs": []map[string]interface{}{
+			{"_index": "transactions", "_type": DocumentTypeTransaction, "_id": "t1", "found": true, "_version": 2, "_source": json.RawMessage(storedFee)},
+			{"_index": "transactions", "_type": DocumentTypeTransaction, "_id": "t2", "found": false},
+		}})
+	}))
+	defer server.Close()
+
+	repo := NewTransactionRepository("transactions", newFakeClient(t, server))
+	list, err := repo.GetByIDs(context.Background(), []string{"t1", "t2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if realtime != "true" {
+		t.Errorf("expected a realtime multi get, got realtime=%q", realtime)
+	}
+	if len(list) != 1 || list[0].ID != "t1" || list[0].Status != transactions.StatusPaid || list[0].Version != 2 {
+		t.Errorf("expected the stored transaction only, got %+v", list)
+	}
+}
+
+func TestMalformedDocumentTellsItsOwner(t *testing.T) {
+	tests := []struct {
+		document string
+		owner    string
+	}{
+		{`{"user_id":"u1","type":"fee","amount":12.5,"currency":"EUR"}`, "u1"},
+		{`{"user_id":"u1","type":"fee","status":"lost","amount":12.5,"currency":"EUR","creation_date":"2026-01-10T10:00:00Z"}`, "u1"},
+		{`{"user_id":"u1","type":"fee","amount":"12.5","currency":"EUR","creation_date":"2026-01-10T10:00:00Z"}`, "u1"},
+		{`{"user_id":1,"type":"fee","amount":12.5,"currency":"EUR","creation_date":"2026-01-10T10:00:00Z"}`, ""},
+		{`{"type":"fee","amount":12.5,"currency":"EUR","creation_date":"2026-01-10T10:00:00Z"}`, ""},
+		{`{"user_id":"u1",`, ""},
+	}
+
+	for _, tt := range tests {
+		_, err := decodeTransaction("t1", []byte(tt.document))
+		owned, ok := err.(transactions.OwnedError)
+		if !ok {
+			t.Errorf("%s: expected an error telling the owner, got %v", tt.document, err)
+			continue
+		}
+		if owned.Owner() != tt.owner {
+			t.Errorf("%s: expected the owner %q, got %q", tt.document, tt.owner, owned.Owner())
+		}
+	}
+}
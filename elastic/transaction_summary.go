@@ -0,0 +1,120 @@
+package elastic
+
+import (
+	"context"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/transactions"
+	"github.com/pkg/errors"
+	elasticapi "gopkg.in/olivere/elastic.v5"
+)
+
+const (
+	aggByType     = "by_type"
+	aggByStatus   = "by_status"
+	aggHistogram  = "histogram"
+	aggByCurrency = "by_currency"
+	aggAmount     = "amount"
+
+	// maxSummaryBuckets bounds the number of distinct types, statuses and currencies reported
+	maxSummaryBuckets = 100
+)
+
+// GetSummaryByUser computes the summary with aggregations only, no document is fetched
+func (repo *transactionRepository) GetSummaryByUser(ctx context.Context, request transactions.SummaryRequest) (*transactions.TransactionsSummary, error) {
+	if repo.elasticClient == nil {
+		return nil, ErrElasticSearchNotReachable
+	}
+
+	histogram := elasticapi.NewDateHistogramAggregation().
+		Field("creation_date").
+		Interval(string(request.Interval)).
+		MinDocCount(0).
+		SubAggregation(aggByCurrency, getAmountByCurrencyAggregation())
+	if request.Query.Location != nil {
+		histogram = histogram.TimeZone(request.Query.Location.String())
+	}
+
+	searchResult, err := repo.elasticClient.Search(repo.IndexName).
+		Index(repo.IndexName).
+		Type(DocumentTypeTransaction).
+		Query(buildQuery(request.Query)).
+		Size(0).
+		Aggregation(aggByCurrency, getAmountByCurrencyAggregation()).
+		Aggregation(aggByType, getTermsWithAmountAggregation("type")).
+		Aggregation(aggByStatus, getTermsWithAmountAggregation("status")).
+		Aggregation(aggHistogram, histogram).
+		Do(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error during elastic search")
+	}
+
+	summary := &transactions.TransactionsSummary{
+		Count:     searchResult.TotalHits(),
+		Amounts:   getAmountsByCurrency(searchResult.Aggregations),
+		ByType:    getSummaryBuckets(searchResult.Aggregations, aggByType),
+		ByStatus:  getSummaryBuckets(searchResult.Aggregations, aggByStatus),
+		Histogram: []*transactions.HistogramBucket{},
+	}
+
+	if items, ok := searchResult.Aggregations.DateHistogram(aggHistogram); ok {
+		for _, bucket := range items.Buckets {
+			summary.Histogram = append(summary.Histogram, &transactions.HistogramBucket{
+				// keys are epoch milliseconds
+				Date:    time.Unix(0, int64(bucket.Key)*int64(time.Millisecond)).UTC(),
+				Count:   bucket.DocCount,
+				Amounts: getAmountsByCurrency(bucket.Aggregations),
+			})
+		}
+	}
+
+	return summary, nil
+}
+
+func getAmountByCurrencyAggregation() *elasticapi.TermsAggregation {
+	return elasticapi.NewTermsAggregation().
+		Field("currency").
+		Size(maxSummaryBuckets).
+		SubAggregation(aggAmount, elasticapi.NewSumAggregation().Field("amount"))
+}
+
+func getTermsWithAmountAggregation(field string) *elasticapi.TermsAggregation {
+	return elasticapi.NewTermsAggregation().
+		Field(field).
+		Size(maxSummaryBuckets).
+		SubAggregation(aggByCurrency, getAmountByCurrencyAggregation())
+}
+
+func getSummaryBuckets(aggs elasticapi.Aggregations, name string) []*transactions.SummaryBucket {
+	buckets := []*transactions.SummaryBucket{}
+
+	items, ok := aggs.Terms(name)
+	if !ok {
+		return buckets
+	}
+	for _, bucket := range items.Buckets {
+		key, _ := bucket.Key.(string)
+		buckets = append(buckets, &transactions.SummaryBucket{
+			Key:     key,
+			Count:   bucket.DocCount,
+			Amounts: getAmountsByCurrency(bucket.Aggregations),
+		})
+	}
+	return buckets
+}
+
+func getAmountsByCurrency(aggs elasticapi.Aggregations) map[string]float64 {
+	amounts := map[string]float64{}
+
+	items, ok := aggs.Terms(aggByCurrency)
+	if !ok {
+		return amounts
+	}
+	for _, bucket := range items.Buckets {
+		currency, _ := bucket.Key.(string)
+		if sum, ok := bucket.Sum(aggAmount); ok && sum.Value != nil {
+			amounts[currency] = *sum.Value
+		}
+	}
+	return amounts
+}
@@ -0,0 +1,194 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+
+	apierror "github.com/fsilberstein/parameters-issue/errors"
+	"github.com/fsilberstein/parameters-issue/webhooks"
+	"github.com/pkg/errors"
+	elasticapi "gopkg.in/olivere/elastic.v5"
+)
+
+const (
+	DocumentTypeSubscription = "subscription"
+	DocumentTypeDelivery     = "delivery"
+
+	// maxSubscriptions is the most subscriptions read for a user, well above what a user can create
+	maxSubscriptions = 100
+)
+
+type webhookRepository struct {
+	SubscriptionIndex string
+	DeliveryIndex     string
+	elasticClient     *elasticapi.Client
+}
+
+// NewWebhookRepository stores the subscriptions and the delivery log in their own indices
+func NewWebhookRepository(subscriptionIndex, deliveryIndex string, elasticClient *elasticapi.Client) webhooks.Repository {
+	return &webhookRepository{
+		SubscriptionIndex: subscriptionIndex,
+		DeliveryIndex:     deliveryIndex,
+		elasticClient:     elasticClient,
+	}
+}
+
+// CreateWebhookIndices creates the indices of the subscriptions and of the delivery log with their mapping, the
+// subscriptions being read by user and the deliveries by subscription
+func CreateWebhookIndices(ctx context.Context, elasticClient *elasticapi.Client, subscriptionIndex, deliveryIndex string) error {
+	if err := createIndex(ctx, elasticClient, subscriptionIndex, DocumentTypeSubscription, keywords("id", "user_id", "events", "types")); err != nil {
+		return err
+	}
+	properties := keywords("id", "subscription_id", "event_id", "event_type", "result")
+	properties["date"] = map[string]interface{}{"type": "date"}
+	return createIndex(ctx, elasticClient, deliveryIndex, DocumentTypeDelivery, properties)
+}
+
+func (repo *webhookRepository) CreateSubscription(ctx context.Context, subscription *webhooks.Subscription) error {
+	if repo.elasticClient == nil {
+		return ErrElasticSearchNotReachable
+	}
+
+	_, err := repo.elasticClient.Index().
+		Index(repo.SubscriptionIndex).
+		Type(DocumentTypeSubscription).
+		Id(subscription.ID).
+		OpType("create").
+		BodyJson(subscription).
+		Refresh("wait_for"). // the subscription is listed as soon as it is created
+		Do(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error during elastic index")
+	}
+	return nil
+}
+
+func (repo *webhookRepository) GetSubscription(ctx context.Context, subscriptionID string) (*webhooks.Subscription, error) {
+	if repo.elasticClient == nil {
+		return nil, ErrElasticSearchNotReachable
+	}
+
+	getResult, err := repo.elasticClient.Get().
+		Index(repo.SubscriptionIndex).
+		Type(DocumentTypeSubscription).
+		Id(subscriptionID).
+		Do(ctx)
+	if elasticapi.IsNotFound(err) || (err == nil && (!getResult.Found || getResult.Source == nil)) {
+		return nil, apierror.NewNotFoundError("webhook")
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error during elastic get")
+	}
+
+	var subscription webhooks.Subscription
+	if err := json.Unmarshal(*getResult.Source, &subscription); err != nil {
+		return nil, errors.Wrap(err, "malformed subscription document")
+	}
+	subscription.ID = getResult.Id
+	return &subscription, nil
+}
+
+func (repo *webhookRepository) GetSubscriptionsByUser(ctx context.Context, userID string) ([]*webhooks.Subscription, error) {
+	if repo.elasticClient == nil {
+		return nil, ErrElasticSearchNotReachable
+	}
+
+	searchResult, err := repo.elasticClient.Search(repo.SubscriptionIndex).
+		Index(repo.SubscriptionIndex).
+		Type(DocumentTypeSubscription).
+		Query(elasticapi.NewTermQuery("user_id", userID)).
+		Size(maxSubscriptions).
+		Do(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error during elastic search")
+	}
+
+	subscriptions := []*webhooks.Subscription{}
+	if searchResult.Hits == nil {
+		return subscriptions, nil
+	}
+	for _, hit := range searchResult.Hits.Hits {
+		if hit.Source == nil {
+			continue
+		}
+		var subscription webhooks.Subscription
+		if err := json.Unmarshal(*hit.Source, &subscription); err != nil {
+			return nil, errors.Wrap(err, "malformed subscription document")
+		}
+		subscription.ID = hit.Id
+		subscriptions = append(subscriptions, &subscription)
+	}
+	return subscriptions, nil
+}
+
+func (repo *webhookRepository) DeleteSubscription(ctx context.Context, subscriptionID string) error {
+	if repo.elasticClient == nil {
+		return ErrElasticSearchNotReachable
+	}
+
+	_, err := repo.elasticClient.Delete().
+		Index(repo.SubscriptionIndex).
+		Type(DocumentTypeSubscription).
+		Id(subscriptionID).
+		Refresh("wait_for").
+		Do(ctx)
+	if elasticapi.IsNotFound(err) {
+		return apierror.NewNotFoundError("webhook")
+	}
+	if err != nil {
+		return errors.Wrap(err, "error during elastic delete")
+	}
+	return nil
+}
+
+func (repo *webhookRepository) LogDelivery(ctx context.Context, delivery *webhooks.Delivery) error {
+	if repo.elasticClient == nil {
+		return ErrElasticSearchNotReachable
+	}
+
+	indexService := repo.elasticClient.Index().
+		Index(repo.DeliveryIndex).
+		Type(DocumentTypeDelivery).
+		BodyJson(delivery)
+	if delivery.ID != "" {
+		indexService = indexService.Id(delivery.ID)
+	}
+	if _, err := indexService.Do(ctx); err != nil {
+		return errors.Wrap(err, "error during elastic index")
+	}
+	return nil
+}
+
+func (repo *webhookRepository) GetDeliveries(ctx context.Context, subscriptionID string, size int) ([]*webhooks.Delivery, error) {
+	if repo.elasticClient == nil {
+		return nil, ErrElasticSearchNotReachable
+	}
+
+	searchResult, err := repo.elasticClient.Search(repo.DeliveryIndex).
+		Index(repo.DeliveryIndex).
+		Type(DocumentTypeDelivery).
+		Query(elasticapi.NewTermQuery("subscription_id", subscriptionID)).
+		SortBy(elasticapi.NewFieldSort("date").Desc()).
+		Size(size).
+		Do(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error during elastic search")
+	}
+
+	deliveries := []*webhooks.Delivery{}
+	if searchResult.Hits == nil {
+		return deliveries, nil
+	}
+	for _, hit := range searchResult.Hits.Hits {
+		if hit.Source == nil {
+			continue
+		}
+		var delivery webhooks.Delivery
+		if err := json.Unmarshal(*hit.Source, &delivery); err != nil {
+			return nil, errors.Wrap(err, "malformed delivery document")
+		}
+		delivery.ID = hit.Id
+		deliveries = append(deliveries, &delivery)
+	}
+	return deliveries, nil
+}
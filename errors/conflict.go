@@ -0,0 +1,32 @@
+package errors
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+)
+
+type errConflict struct {
+	error
+}
+
+// NewConflictError creates a special error that, when processed by GoKit's DefaultErrorEncoder, will translate to a
+// fully-fledged ReST HTTP response:
+// - HTTP status code 409
+// - JSON response body like '{ "error" : "Conflict: idempotency key already used" }'
+func NewConflictError(msg string) error {
+	return errConflict{stderrors.New(fmt.Sprintf("Conflict: %s", msg))}
+}
+
+// MarshalJSON lets GoKit's DefaultErrorEncoder set the proper HTTP response body
+func (e errConflict) MarshalJSON() ([]byte, error) {
+	outputBody := map[string]interface{}{}
+	outputBody["error"] = e.error.Error()
+	return json.Marshal(outputBody)
+}
+
+// StatusCode lets GoKit's DefaultErrorEncoder set the proper HTTP status code in response
+func (e errConflict) StatusCode() int {
+	return http.StatusConflict
+}
@@ -30,3 +30,9 @@ func (e errNotFound) MarshalJSON() ([]byte, error) {
 func (e errNotFound) StatusCode() int {
 	return http.StatusNotFound
 }
+
+// IsNotFound tells whether the error was created by NewNotFoundError
+func IsNotFound(err error) bool {
+	_, ok := err.(errNotFound)
+	return ok
+}
@@ -0,0 +1,153 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+	"github.com/fsilberstein/parameters-issue/logger"
+	"github.com/fsilberstein/parameters-issue/transactions"
+	"go.uber.org/zap"
+)
+
+const (
+	initialBackoff = time.Second
+	maxBackoff     = time.Minute
+)
+
+// Consumer upserts the transactions of the events read from a Source into the repository. A message is
+// acknowledged once handled or sent to the dead-letter sink, invalid events being sent there at once and the
+// others after maxAttempts failed attempts.
+type Consumer struct {
+	source      Source
+	repo        transactions.Repository
+	deadLetter  DeadLetterSink
+	maxAttempts int
+}
+
+// NewConsumer initializes a consumer, maxAttempts being at least 1
+func NewConsumer(source Source, repo transactions.Repository, deadLetter DeadLetterSink, maxAttempts int) *Consumer {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &Consumer{
+		source:      source,
+		repo:        repo,
+		deadLetter:  deadLetter,
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Run consumes the messages until ctx is done or the source or the dead-letter sink fail. A message being
+// handled when Run returns is not acknowledged, it is delivered again on the next run.
+func (c *Consumer) Run(ctx context.Context) error {
+	for {
+		message, err := c.source.Receive(ctx)
+		if err != nil {
+			return err
+		}
+		if err := c.handle(ctx, message); err != nil {
+			return err
+		}
+		if err := c.source.Ack(ctx, message); err != nil {
+			return err
+		}
+	}
+}
+
+// handle applies the message, retrying with an exponential backoff, and sends it to the dead-letter sink when it
+// can not be applied. It only fails when the message must not be acknowledged.
+func (c *Consumer) handle(ctx context.Context, message *Message) error {
+	attempt := 1
+	backoff := initialBackoff
+	for {
+		err := c.apply(ctx, message)
+		if err == nil {
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		_, invalid := err.(invalidEventError)
+		if invalid || attempt >= c.maxAttempts {
+			logger.LogStdErr.Error("event sent to the dead-letter sink", zap.Int64("offset", message.Offset), zap.Error(err))
+			if err := c.deadLetter.Send(ctx, message, attempt, err); err != nil {
+				return fmt.Errorf("could not send the event at offset %d to the dead-letter sink: %s", message.Offset, err)
+			}
+			return nil
+		}
+
+		logger.LogStdErr.Error("event handling failed, retrying", zap.Int64("offset", message.Offset), zap.Int("attempt", attempt), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		attempt++
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// apply writes the changes of the event to the transaction, unless the event is stale. Applying an event again
+// gives the same transaction, the event being the last one applied.
+func (c *Consumer) apply(ctx context.Context, message *Message) error {
+	event, err := decodeEvent(message.Data)
+	if err != nil {
+		return err
+	}
+
+	var transaction *transactions.Transaction
+	switch event.Type {
+	case TransactionPaid:
+		data, err := event.decodePaid()
+		if err != nil {
+			return err
+		}
+		// the transaction may not be found yet if its creation is late, the event is then retried
+		transaction, err = c.repo.GetByID(ctx, data.TransactionID)
+		if err != nil {
+			return err
+		}
+		if event.isStale(transaction) {
+			logger.LogStdOut.Info("stale event ignored", zap.Int64("offset", message.Offset), zap.String("transaction_id", data.TransactionID))
+			return nil
+		}
+		if transaction.Status == transactions.StatusPaid {
+			return nil
+		}
+		transaction.Status = transactions.StatusPaid
+	default:
+		data, err := event.decodeTransaction()
+		if err != nil {
+			return err
+		}
+		transaction, err = c.repo.GetByID(ctx, data.ID)
+		switch {
+		case errors.IsNotFound(err):
+			if transaction, err = data.newTransaction(event.OccurredAt); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case transaction.UserID != data.UserID:
+			return invalidEventError{reason: "the transaction belongs to another user"}
+		case event.isStale(transaction):
+			logger.LogStdOut.Info("stale event ignored", zap.Int64("offset", message.Offset), zap.String("transaction_id", data.ID))
+			return nil
+		default:
+			data.apply(transaction)
+		}
+		if err := validateTransaction(transaction); err != nil {
+			return err
+		}
+	}
+
+	if event.OccurredAt != nil {
+		transaction.LastEventAt = event.OccurredAt
+	}
+	return c.repo.Save(ctx, transaction)
+}
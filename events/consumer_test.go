@@ -0,0 +1,189 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+// memorySource delivers its messages, then blocks until ctx is done
+type memorySource struct {
+	mu       sync.Mutex
+	messages []*Message
+	acked    int
+}
+
+func (s *memorySource) Receive(ctx context.Context) (*Message, error) {
+	s.mu.Lock()
+	if len(s.messages) == 0 {
+		s.mu.Unlock()
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	message := s.messages[0]
+	s.messages = s.messages[1:]
+	s.mu.Unlock()
+	return message, nil
+}
+
+func (s *memorySource) Ack(ctx context.Context, message *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.acked++
+	return nil
+}
+
+func (s *memorySource) Close() error {
+	return nil
+}
+
+type memoryDeadLetterSink struct {
+	offsets []int64
+}
+
+func (s *memoryDeadLetterSink) Send(ctx context.Context, message *Message, attempts int, reason error) error {
+	s.offsets = append(s.offsets, message.Offset)
+	return nil
+}
+
+// memoryRepository stores the transactions by ID, only GetByID and Save are used by the consumer
+type memoryRepository struct {
+	transactions.Repository
+	mu    sync.Mutex
+	items map[string]transactions.Transaction
+}
+
+func (r *memoryRepository) GetByID(ctx context.Context, transactionID string) (*transactions.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	transaction, ok := r.items[transactionID]
+	if !ok {
+		return nil, errors.NewNotFoundError("transaction")
+	}
+	return &transaction, nil
+}
+
+func (r *memoryRepository) Save(ctx context.Context, transaction *transactions.Transaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	transaction.UpdatedAt = &now
+	r.items[transaction.ID] = *transaction
+	return nil
+}
+
+func message(offset int64, eventType EventType, occurredAt time.Time, data string) *Message {
+	return &Message{
+		Offset: offset,
+		Data:   []byte(fmt.Sprintf(`{"id":"e%d","type":%q,"occurred_at":%q,"data":%s}`, offset, eventType, occurredAt.Format(time.RFC3339Nano), data)),
+	}
+}
+
+// run consumes the messages of the source until all of them are acknowledged
+func run(t *testing.T, consumer *Consumer, source *memorySource, count int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- consumer.Run(ctx)
+	}()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case err := <-done:
+			t.Fatalf("the consumer stopped before the messages were handled: %v", err)
+		case <-deadline:
+			t.Fatal("the messages were not handled in time")
+		case <-time.After(10 * time.Millisecond):
+		}
+		source.mu.Lock()
+		acked := source.acked
+		source.mu.Unlock()
+		if acked == count {
+			break
+		}
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected the consumer to stop with its context, got %v", err)
+	}
+}
+
+func TestConsumerAppliesOnlyTheFieldsOfTheEvents(t *testing.T) {
+	created := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	repo := &memoryRepository{items: map[string]transactions.Transaction{}}
+	source := &memorySource{messages: []*Message{
+		message(1, TransactionCreated, created, `{"id":"t1","user_id":"u1","type":"payment","amount":12.5,"currency":"EUR"}`),
+		message(2, TransactionUpdated, created.Add(time.Hour), `{"id":"t1","user_id":"u1","description":"rent"}`),
+		message(3, TransactionPaid, created.Add(2*time.Hour), `{"transaction_id":"t1"}`),
+	}}
+	deadLetter := &memoryDeadLetterSink{}
+	run(t, NewConsumer(source, repo, deadLetter, 1), source, 3)
+
+	if len(deadLetter.offsets) != 0 {
+		t.Fatalf("expected no dead letter, got offsets %v", deadLetter.offsets)
+	}
+	transaction := repo.items["t1"]
+	if !transaction.CreationDate.Equal(created) {
+		t.Errorf("expected the creation date of the first event to be kept, got %s", transaction.CreationDate)
+	}
+	if transaction.Status != transactions.StatusPaid || transaction.Amount != 12.5 || transaction.Description != "rent" {
+		t.Errorf("expected the changes of every event, got %+v", transaction)
+	}
+	if transaction.LastEventAt == nil || !transaction.LastEventAt.Equal(created.Add(2*time.Hour)) {
+		t.Errorf("expected the date of the last event, got %v", transaction.LastEventAt)
+	}
+}
+
+func TestConsumerIgnoresStaleEvents(t *testing.T) {
+	updated := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	repo := &memoryRepository{items: map[string]transactions.Transaction{
+		// written through the API, after the events
+		"t1": {ID: "t1", UserID: "u1", Type: transactions.TypeFee, Status: transactions.StatusCancelled, Amount: 3, Currency: "EUR", CreationDate: updated, UpdatedAt: &updated},
+		// written by the consumer
+		"t2": {ID: "t2", UserID: "u1", Type: transactions.TypeFee, Status: transactions.StatusOpen, Amount: 3, Currency: "EUR", CreationDate: updated, UpdatedAt: &updated, LastEventAt: &updated},
+	}}
+	source := &memorySource{messages: []*Message{
+		message(1, TransactionUpdated, updated.Add(-time.Minute), `{"id":"t1","user_id":"u1","status":"open"}`),
+		message(2, TransactionPaid, updated.Add(-time.Minute), `{"transaction_id":"t1"}`),
+		message(3, TransactionUpdated, updated.Add(-time.Minute), `{"id":"t2","user_id":"u1","amount":4}`),
+		message(4, TransactionUpdated, updated.Add(time.Minute), `{"id":"t2","user_id":"u1","amount":5}`),
+	}}
+	run(t, NewConsumer(source, repo, &memoryDeadLetterSink{}, 1), source, 4)
+
+	if status := repo.items["t1"].Status; status != transactions.StatusCancelled {
+		t.Errorf("expected the events older than the last write to be ignored, got status %s", status)
+	}
+	if amount := repo.items["t2"].Amount; amount != 5 {
+		t.Errorf("expected the events to be compared to the last event applied, got amount %v", amount)
+	}
+}
+
+func TestConsumerSendsInvalidEventsToTheDeadLetterSink(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	repo := &memoryRepository{items: map[string]transactions.Transaction{
+		"t1": {ID: "t1", UserID: "u1", Type: transactions.TypeFee, Amount: 3, Currency: "EUR", CreationDate: now},
+	}}
+	source := &memorySource{messages: []*Message{
+		message(1, TransactionUpdated, now, `{"id":"t1","user_id":"u2","amount":4}`),
+		message(2, TransactionUpdated, now, `{"id":"t1","user_id":"u1","currency":"euro"}`),
+		message(3, TransactionCreated, now, `{"id":"t2","user_id":"u1","type":"fee","currency":"EUR","unknown":1}`),
+		message(4, TransactionCreated, now, `{"id":"t3","user_id":"u1","type":"fee","currency":"EUR"}`),
+	}}
+	deadLetter := &memoryDeadLetterSink{}
+	run(t, NewConsumer(source, repo, deadLetter, 3), source, 4)
+
+	if fmt.Sprint(deadLetter.offsets) != "[1 2 3]" {
+		t.Errorf("expected the invalid events to be sent to the dead-letter sink, got offsets %v", deadLetter.offsets)
+	}
+	if amount := repo.items["t1"].Amount; amount != 3 {
+		t.Errorf("expected the transaction to be left unchanged, got amount %v", amount)
+	}
+	if _, ok := repo.items["t3"]; !ok {
+		t.Error("expected the valid event to be applied")
+	}
+}
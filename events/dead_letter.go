@@ -0,0 +1,63 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// DeadLetterSink keeps the messages which could not be handled, for them to be inspected and replayed
+type DeadLetterSink interface {
+	Send(ctx context.Context, message *Message, attempts int, reason error) error
+}
+
+type deadLetter struct {
+	Offset   int64     `json:"offset"`
+	Data     string    `json:"data"`
+	Attempts int       `json:"attempts"`
+	Reason   string    `json:"reason"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// FileDeadLetterSink appends the messages to an NDJSON file, the original message being in the `data` field
+type FileDeadLetterSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileDeadLetterSink opens the file, creating it if needed
+func NewFileDeadLetterSink(path string) (*FileDeadLetterSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileDeadLetterSink{file: file}, nil
+}
+
+// Send writes the message and syncs the file, so that the message can be acknowledged safely
+func (s *FileDeadLetterSink) Send(ctx context.Context, message *Message, attempts int, reason error) error {
+	line, err := json.Marshal(deadLetter{
+		Offset:   message.Offset,
+		Data:     string(message.Data),
+		Attempts: attempts,
+		Reason:   reason.Error(),
+		FailedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Close closes the file
+func (s *FileDeadLetterSink) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,188 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+// EventType is the kind of change an event reports
+type EventType string
+
+// All the event types handled by the consumer
+const (
+	TransactionCreated EventType = "transaction-created"
+	TransactionUpdated EventType = "transaction-updated"
+	TransactionPaid    EventType = "transaction-paid"
+)
+
+// Event is the envelope of the messages read from a Source. The data of transaction-created and
+// transaction-updated events holds the ID and the user of the transaction and the fields which changed, like a line
+// of a bulk request where any other field may be missing. The data of a transaction-paid event only tells the
+// transaction: {"transaction_id": "..."}. An event which occurred before the last change of the transaction is
+// ignored.
+type Event struct {
+	ID         string          `json:"id"`
+	Type       EventType       `json:"type"`
+	OccurredAt *time.Time      `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// transactionData is the data of transaction-created and transaction-updated events, a missing field leaves the
+// stored value unchanged
+type transactionData struct {
+	ID              string                       `json:"id"`
+	UserID          string                       `json:"user_id"`
+	Type            *string                      `json:"type"`
+	Status          *transactions.Status         `json:"status"`
+	Amount          *float64                     `json:"amount"`
+	Currency        *string                      `json:"currency"`
+	Counterparty    *transactions.Counterparty   `json:"counterparty"`
+	Description     *string                      `json:"description"`
+	Reference       *string                      `json:"reference"`
+	CreationDate    *time.Time                   `json:"creation_date"`
+	DueDate         *time.Time                   `json:"due_date"`
+	LinkedDocuments []*transactions.DocumentLink `json:"linked_documents"`
+}
+
+type paidData struct {
+	TransactionID string `json:"transaction_id"`
+}
+
+// invalidEventError is returned for events which can not be handled however many times they are retried
+type invalidEventError struct {
+	reason string
+}
+
+func (e invalidEventError) Error() string {
+	return "invalid event: " + e.reason
+}
+
+func decodeStrict(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+func decodeEvent(data []byte) (*Event, error) {
+	var event Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, invalidEventError{reason: err.Error()}
+	}
+	switch event.Type {
+	case TransactionCreated, TransactionUpdated, TransactionPaid:
+	default:
+		return nil, invalidEventError{reason: fmt.Sprintf("unknown type '%s'", event.Type)}
+	}
+	if len(event.Data) == 0 {
+		return nil, invalidEventError{reason: "missing data"}
+	}
+	return &event, nil
+}
+
+// decodeTransaction reads the data of a transaction-created or transaction-updated event
+func (e *Event) decodeTransaction() (*transactionData, error) {
+	var data transactionData
+	if err := decodeStrict(e.Data, &data); err != nil {
+		return nil, invalidEventError{reason: err.Error()}
+	}
+	if data.ID == "" {
+		return nil, invalidEventError{reason: "field 'id' is mandatory"}
+	}
+	if data.UserID == "" {
+		return nil, invalidEventError{reason: "field 'user_id' is mandatory"}
+	}
+	return &data, nil
+}
+
+// isStale tells whether the event occurred before the last change of the stored transaction: the last event
+// applied to it, or else its last write. An event without occurrence date is never stale.
+func (e *Event) isStale(stored *transactions.Transaction) bool {
+	if e.OccurredAt == nil {
+		return false
+	}
+	last := stored.LastEventAt
+	if last == nil {
+		last = stored.UpdatedAt
+	}
+	return last != nil && e.OccurredAt.Before(*last)
+}
+
+// apply sets the fields carried by the event on the transaction
+func (d *transactionData) apply(transaction *transactions.Transaction) {
+	if d.Type != nil {
+		transaction.Type = *d.Type
+	}
+	if d.Status != nil {
+		transaction.Status = *d.Status
+	}
+	if d.Amount != nil {
+		transaction.Amount = *d.Amount
+	}
+	if d.Currency != nil {
+		transaction.Currency = *d.Currency
+	}
+	if d.Counterparty != nil {
+		transaction.Counterparty = d.Counterparty
+	}
+	if d.Description != nil {
+		transaction.Description = *d.Description
+	}
+	if d.Reference != nil {
+		transaction.Reference = *d.Reference
+	}
+	if d.CreationDate != nil {
+		transaction.CreationDate = *d.CreationDate
+	}
+	if d.DueDate != nil {
+		transaction.DueDate = d.DueDate
+	}
+	if d.LinkedDocuments != nil {
+		transaction.LinkedDocuments = d.LinkedDocuments
+	}
+}
+
+// newTransaction builds the transaction of an event about a transaction which is not stored yet. Like through the
+// API, it is open unless told otherwise, and it was created when the event occurred unless told otherwise.
+func (d *transactionData) newTransaction(occurredAt *time.Time) (*transactions.Transaction, error) {
+	transaction := &transactions.Transaction{ID: d.ID, UserID: d.UserID, Status: transactions.StatusOpen}
+	if occurredAt != nil {
+		transaction.CreationDate = *occurredAt
+	}
+	d.apply(transaction)
+	if transaction.CreationDate.IsZero() {
+		return nil, invalidEventError{reason: "field 'creation_date' is mandatory for a new transaction"}
+	}
+	return transaction, nil
+}
+
+// validateTransaction checks the transaction an event results in against the Transaction model
+func validateTransaction(transaction *transactions.Transaction) error {
+	err := transactions.NewTransaction{
+		Type:            transaction.Type,
+		Status:          transaction.Status,
+		Amount:          transaction.Amount,
+		Currency:        transaction.Currency,
+		CreationDate:    &transaction.CreationDate,
+		DueDate:         transaction.DueDate,
+		LinkedDocuments: transaction.LinkedDocuments,
+	}.Validate()
+	if err != nil {
+		return invalidEventError{reason: err.Error()}
+	}
+	return nil
+}
+
+func (e *Event) decodePaid() (*paidData, error) {
+	var data paidData
+	if err := decodeStrict(e.Data, &data); err != nil {
+		return nil, invalidEventError{reason: err.Error()}
+	}
+	if data.TransactionID == "" {
+		return nil, invalidEventError{reason: "field 'transaction_id' is mandatory"}
+	}
+	return &data, nil
+}
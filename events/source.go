@@ -0,0 +1,114 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message is a raw event read from a Source
+type Message struct {
+	// Offset is the position the consumption resumes from once the message is acknowledged
+	Offset int64
+	Data   []byte
+}
+
+// Source delivers the messages in order. A message which is not acknowledged is delivered again after a restart,
+// which makes the delivery at-least-once: the handling of a message must be idempotent.
+type Source interface {
+	// Receive blocks until a message is available or ctx is done
+	Receive(ctx context.Context) (*Message, error)
+	// Ack commits the message, and all the messages before it
+	Ack(ctx context.Context, message *Message) error
+	Close() error
+}
+
+// FileSource reads events from an NDJSON file, one event per line, following the file as lines are appended.
+// The offset of the acknowledged messages is kept next to the file, in <path>.offset.
+type FileSource struct {
+	file         *os.File
+	reader       *bufio.Reader
+	offsetPath   string
+	offset       int64
+	pollInterval time.Duration
+}
+
+// NewFileSource opens the file and resumes after the last acknowledged message
+func NewFileSource(path string, pollInterval time.Duration) (*FileSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &FileSource{file: file, offsetPath: path + ".offset", pollInterval: pollInterval}
+
+	content, err := ioutil.ReadFile(source.offsetPath)
+	if err == nil {
+		source.offset, err = strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("invalid offset file %s: %s", source.offsetPath, err)
+		}
+	} else if !os.IsNotExist(err) {
+		file.Close()
+		return nil, err
+	}
+
+	if _, err := file.Seek(source.offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	source.reader = bufio.NewReader(file)
+
+	return source, nil
+}
+
+// Receive returns the next complete line, waiting for the file to grow when all of it has been read
+func (s *FileSource) Receive(ctx context.Context) (*Message, error) {
+	for {
+		line, err := s.reader.ReadBytes('\n')
+		if err == nil {
+			s.offset += int64(len(line))
+			if data := bytes.TrimSpace(line); len(data) > 0 {
+				return &Message{Offset: s.offset, Data: data}, nil
+			}
+			continue
+		}
+		if err != io.EOF {
+			return nil, err
+		}
+
+		// the last line may still be being written, it is read again once complete
+		if _, err := s.file.Seek(s.offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		s.reader.Reset(s.file)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
+
+// Ack writes the offset of the message, through a rename so that a crash never leaves a partial offset file
+func (s *FileSource) Ack(ctx context.Context, message *Message) error {
+	tmp := s.offsetPath + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.FormatInt(message.Offset, 10)), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.offsetPath)
+}
+
+// Close closes the file
+func (s *FileSource) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,70 @@
+package events
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileSourceResumesAfterTheAcknowledgedMessages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "events.ndjson")
+	if err := ioutil.WriteFile(path, []byte("{\"id\":\"1\"}\n\n{\"id\":\"2\"}\n{\"id\":"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := NewFileSource(path, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	first, err := source.Receive(ctx)
+	if err != nil || string(first.Data) != `{"id":"1"}` {
+		t.Fatalf("expected the first line, got %v %v", first, err)
+	}
+	if err := source.Ack(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	// the blank line is skipped
+	second, err := source.Receive(ctx)
+	if err != nil || string(second.Data) != `{"id":"2"}` {
+		t.Fatalf("expected the second line, got %v %v", second, err)
+	}
+
+	// the incomplete last line is not delivered until it ends
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if message, err := source.Receive(timeout); err != context.DeadlineExceeded {
+		t.Fatalf("expected to wait for the last line, got %v %v", message, err)
+	}
+	source.Close()
+
+	// the second message was not acknowledged, it is delivered again
+	source, err = NewFileSource(path, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+	again, err := source.Receive(ctx)
+	if err != nil || string(again.Data) != `{"id":"2"}` {
+		t.Fatalf("expected the unacknowledged line, got %v %v", again, err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("\"3\"}\n")
+	file.Close()
+	last, err := source.Receive(ctx)
+	if err != nil || string(last.Data) != `{"id":"3"}` {
+		t.Fatalf("expected the completed line, got %v %v", last, err)
+	}
+}
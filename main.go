@@ -8,23 +8,35 @@ import (
 	"os/signal"
 	"strconv"
 	"syscall"
+	"time"
 
+	"github.com/fsilberstein/parameters-issue/camt"
 	"github.com/fsilberstein/parameters-issue/config"
+	"github.com/fsilberstein/parameters-issue/duplicates"
 	"github.com/fsilberstein/parameters-issue/elastic"
+	"github.com/fsilberstein/parameters-issue/events"
 	"github.com/fsilberstein/parameters-issue/logger"
+	"github.com/fsilberstein/parameters-issue/recurring"
+	"github.com/fsilberstein/parameters-issue/rules"
 	"github.com/fsilberstein/parameters-issue/transactions"
+	"github.com/fsilberstein/parameters-issue/webhooks"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
 	appName = "bookkeeping-transaction-viewer"
+
+	shutdownTimeout = 10 * time.Second
+
+	// webhooksCacheTTL and rulesCacheTTL are how long the webhooks and the rules of a user are kept in memory
+	webhooksCacheTTL = 30 * time.Second
+	rulesCacheTTL    = 30 * time.Second
 )
 
 var (
-	err     error
-	errc    chan error
-	brokers []string
+	err  error
+	errc chan error
 )
 
 func init() {
@@ -47,40 +59,156 @@ func main() {
 		logger.LogStdErr.Error(err)
 	}
 
-	// Creates transactions service
+	// Creates the indices of the services missing them, with their mapping
+	if elasticClient != nil {
+		if err := elastic.CreateWebhookIndices(ctx, elasticClient, config.WebhooksIndex, config.DeliveriesIndex); err != nil {
+			logger.LogStdErr.Fatal(err)
+		}
+		if err := elastic.CreateRuleIndex(ctx, elasticClient, config.RulesIndex); err != nil {
+			logger.LogStdErr.Fatal(err)
+		}
+		if err := elastic.CreateDuplicateIndex(ctx, elasticClient, config.DuplicatesIndex); err != nil {
+			logger.LogStdErr.Fatal(err)
+		}
+	}
+
+	// Creates webhooks service, deliveries only reach the registered URL, on a public address
+	webhookRepository := webhooks.NewCachingRepository(elastic.NewWebhookRepository(config.WebhooksIndex, config.DeliveriesIndex, elasticClient), webhooksCacheTTL)
+	dispatcher := webhooks.NewDispatcher(webhookRepository, webhooks.NewClient(config.WebhooksTimeout), config.WebhooksWorkers, config.WebhooksMaxAttempts, config.WebhooksBackoff)
+	defer dispatcher.Close()
+
+	webhooksService, err := webhooks.NewService(webhookRepository)
+	if err != nil {
+		logger.LogStdErr.Error(err)
+	}
+
+	// Creates transactions service, its writes are published to the webhooks
 	var transactionsService transactions.Service
 	var transactionRepository transactions.Repository
 	{
-		transactionRepository = elastic.NewTransactionRepository(config.ElasticIndex, elasticClient)
-		transactionsService, err = transactions.NewService(transactionRepository)
+		transactionRepository = webhooks.NewNotifyingRepository(elastic.NewTransactionRepository(config.ElasticIndex, elasticClient), dispatcher)
+		transactionsService, err = transactions.NewService(transactionRepository, transactions.StatementIssuer{
+			BankID: config.StatementBankID,
+			BIC:    config.StatementBIC,
+			Name:   config.StatementBankName,
+		}, config.BulkBatchSize)
+		if err != nil {
+			logger.LogStdErr.Fatal(err)
+		}
+	}
+
+	// Creates rules service, the listed transactions are categorized by the rules of their user
+	ruleRepository := rules.NewCachingRepository(elastic.NewRuleRepository(config.RulesIndex, elasticClient), rulesCacheTTL)
+	transactionsService = rules.NewCategorizingService(transactionsService, ruleRepository)
+	rulesService, err := rules.NewService(ruleRepository, transactionsService)
+	if err != nil {
+		logger.LogStdErr.Error(err)
+	}
+
+	// Creates duplicates service, merges go through the repository so that they are published to the webhooks
+	duplicatesService, err := duplicates.NewService(elastic.NewDuplicateRepository(config.DuplicatesIndex, elasticClient), transactionsService, transactionRepository, duplicates.SimilarityRule{
+		Window:          config.DuplicatesWindow,
+		AmountTolerance: config.DuplicatesAmountTol,
+		Fields:          config.DuplicatesFields,
+	})
+	if err != nil {
+		logger.LogStdErr.Fatal(err)
+	}
+
+	// Creates recurring service, the history is analyzed from the repository
+	recurringService, err := recurring.NewService(transactionRepository)
+	if err != nil {
+		logger.LogStdErr.Error(err)
+	}
+
+	// Starts the events consumer, dead letters default to the events file suffixed with .dead. It is stopped on
+	// shutdown, the event being handled then is delivered again on the next run.
+	consumerCtx, stopConsumer := context.WithCancel(ctx)
+	consumerDone := make(chan struct{})
+	if config.EventsFile == "" {
+		close(consumerDone)
+	} else {
+		source, err := events.NewFileSource(config.EventsFile, time.Second)
+		if err != nil {
+			logger.LogStdErr.Fatal(err)
+		}
+		deadLetterPath := config.EventsDeadLetter
+		if deadLetterPath == "" {
+			deadLetterPath = config.EventsFile + ".dead"
+		}
+		deadLetter, err := events.NewFileDeadLetterSink(deadLetterPath)
 		if err != nil {
-			logger.LogStdErr.Error(err)
+			logger.LogStdErr.Fatal(err)
 		}
+
+		consumer := events.NewConsumer(source, transactionRepository, deadLetter, config.EventsMaxAttempts)
+		go func() {
+			defer close(consumerDone)
+			defer deadLetter.Close()
+			defer source.Close()
+			if err := consumer.Run(consumerCtx); err != context.Canceled {
+				errc <- err
+			}
+		}()
 	}
 
 	// Transaction endpoint
 	transactionsEndpoint := transactions.MakeEndpoints(transactionsService)
+	camtEndpoint := camt.MakeEndpoints(transactionsService)
+	webhooksEndpoint := webhooks.MakeEndpoints(webhooksService)
+	rulesEndpoint := rules.MakeEndpoints(rulesService)
+	duplicatesEndpoint := duplicates.MakeEndpoints(duplicatesService)
+	recurringEndpoint := recurring.MakeEndpoints(recurringService)
 
 	// Instances a new HTTP server for healthy check and metrics
-	go func() {
-		httpAddr := ":" + strconv.Itoa(config.Port)
-		mux := mux.NewRouter()
+	httpAddr := ":" + strconv.Itoa(config.Port)
+	router := mux.NewRouter()
+	server := &http.Server{Addr: httpAddr, Handler: router}
+
+	// streams never become idle, they are closed for the shutdown not to wait for them
+	shutdown := make(chan struct{})
+	server.RegisterOnShutdown(func() {
+		close(shutdown)
+	})
 
-		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	go func() {
+		router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
 			fmt.Fprintln(w, "Welcome to the my problem API!")
 		})
-		mux.Handle("/metrics", promhttp.Handler())
-		mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		router.Handle("/metrics", promhttp.Handler())
+		router.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
 		})
 
-		// Init and register to the router the various endpoints
-		transactions.MakeHTTPHandler(transactionsEndpoint, mux)
+		// Init and register to the router the various endpoints, the duplicates routes first as the transactions
+		// ones would match them
+		duplicates.MakeHTTPHandler(duplicatesEndpoint, router)
+		transactions.MakeHTTPHandler(transactionsEndpoint, router, shutdown)
+		camt.MakeHTTPHandler(camtEndpoint, router)
+		webhooks.MakeHTTPHandler(webhooksEndpoint, router)
+		rules.MakeHTTPHandler(rulesEndpoint, router)
+		recurring.MakeHTTPHandler(recurringEndpoint, router)
 
 		logger.LogStdOut.Info(fmt.Sprintf("The API is started on port %d", config.Port))
-		errc <- http.ListenAndServe(httpAddr, mux)
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			errc <- err
+		}
 	}()
 
 	logger.LogStdErr.Error(<-errc)
+
+	// lets the requests in progress end
+	shutdownCtx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.LogStdErr.Error(err)
+	}
+
+	stopConsumer()
+	select {
+	case <-consumerDone:
+	case <-shutdownCtx.Done():
+		logger.LogStdErr.Error("the events consumer did not stop in time")
+	}
 }
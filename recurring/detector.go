@@ -0,0 +1,185 @@
+package recurring
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+// minMatchingIntervals is the share of the intervals of a series which must match its cadence, so that a late
+// or an extra occurrence does not hide the series
+const minMatchingIntervals = 0.75
+
+// seriesKey tells the transactions which can belong to the same series
+type seriesKey struct {
+	counterparty string
+	currency     string
+	outgoing     bool
+}
+
+// counterpartyKey identifies the counterparty by its IBAN, or else its name. Without counterparty, the
+// description is used with its digits removed, as the description of a bill often holds a date or a number.
+func counterpartyKey(t *transactions.Transaction) string {
+	if t.Counterparty != nil {
+		if t.Counterparty.IBAN != "" {
+			return strings.ToUpper(strings.Join(strings.Fields(t.Counterparty.IBAN), ""))
+		}
+		if name := strings.TrimSpace(t.Counterparty.Name); name != "" {
+			return strings.ToLower(strings.Join(strings.Fields(name), " "))
+		}
+	}
+	description := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, t.Description)
+	return strings.Join(strings.Fields(description), " ")
+}
+
+// cluster is a set of transactions with a similar amount
+type cluster struct {
+	transactions []*transactions.Transaction
+	total        float64
+}
+
+func (c *cluster) average() float64 {
+	return c.total / float64(len(c.transactions))
+}
+
+// detector gathers the transactions by key, then analyzes them once all are read
+type detector struct {
+	amountTolerance float64
+	byKey           map[seriesKey][]*transactions.Transaction
+}
+
+func newDetector(amountTolerance float64) *detector {
+	return &detector{amountTolerance: amountTolerance, byKey: make(map[seriesKey][]*transactions.Transaction)}
+}
+
+// add keeps the transactions moving money with a known counterparty. A cancelled transaction moved no money, it
+// neither adds an occurrence to a series nor breaks its cadence.
+func (d *detector) add(list []*transactions.Transaction) {
+	for _, t := range list {
+		if !transactions.IsBooked(t) {
+			continue
+		}
+		key := seriesKey{counterparty: counterpartyKey(t), currency: t.Currency, outgoing: transactions.SignedAmount(t) < 0}
+		if key.counterparty == "" {
+			continue
+		}
+		d.byKey[key] = append(d.byKey[key], t)
+	}
+}
+
+// series returns the series found, asOf telling the stopped ones
+func (d *detector) series(asOf time.Time) []*Series {
+	var result []*Series
+	for _, list := range d.byKey {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].CreationDate.Before(list[j].CreationDate) })
+		for _, c := range d.clusters(list) {
+			if series := toSeries(c, asOf); series != nil {
+				result = append(result, series)
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		switch {
+		case a.Stopped != b.Stopped:
+			return !a.Stopped
+		case a.Stopped:
+			return a.LastDate.After(b.LastDate)
+		case !a.NextExpectedDate.Equal(b.NextExpectedDate):
+			return a.NextExpectedDate.Before(b.NextExpectedDate)
+		}
+		return a.Counterparty < b.Counterparty
+	})
+	return result
+}
+
+// clusters splits the transactions by amount, each one joining the closest cluster within the tolerance
+func (d *detector) clusters(list []*transactions.Transaction) []*cluster {
+	var clusters []*cluster
+	for _, t := range list {
+		amount := math.Abs(transactions.SignedAmount(t))
+
+		var closest *cluster
+		closestGap := math.Inf(1)
+		for _, c := range clusters {
+			average := c.average()
+			gap := math.Abs(amount - average)
+			// the cent of margin lets a zero tolerance match amounts rounded differently
+			if gap <= d.amountTolerance*average+0.01 && gap < closestGap {
+				closest, closestGap = c, gap
+			}
+		}
+		if closest == nil {
+			closest = &cluster{}
+			clusters = append(clusters, closest)
+		}
+		closest.transactions = append(closest.transactions, t)
+		closest.total += amount
+	}
+	return clusters
+}
+
+// toSeries fits the first cadence matching the intervals of the cluster, nil when none does
+func toSeries(c *cluster, asOf time.Time) *Series {
+	list := c.transactions
+	for _, spec := range cadences {
+		if len(list) < spec.MinOccurrences {
+			continue
+		}
+
+		matching := 0
+		for i := 1; i < len(list); i++ {
+			days := list[i].CreationDate.Sub(list[i-1].CreationDate).Hours() / 24
+			if math.Abs(days-spec.Days) <= spec.Tolerance {
+				matching++
+			}
+		}
+		if float64(matching) < minMatchingIntervals*float64(len(list)-1) {
+			continue
+		}
+
+		first, last := list[0], list[len(list)-1]
+		next := spec.next(last.CreationDate)
+		series := &Series{
+			Counterparty:     counterpartyName(last),
+			Description:      last.Description,
+			Currency:         last.Currency,
+			Cadence:          spec.Cadence,
+			Occurrences:      len(list),
+			FirstDate:        first.CreationDate,
+			LastDate:         last.CreationDate,
+			NextExpectedDate: next,
+			Stopped:          asOf.Sub(next).Hours()/24 > spec.Tolerance,
+		}
+
+		var total float64
+		for _, t := range list {
+			total += transactions.SignedAmount(t)
+			series.TransactionIDs = append(series.TransactionIDs, t.ID)
+		}
+		series.AverageAmount = math.Round(total/float64(len(list))*100) / 100
+		return series
+	}
+	return nil
+}
+
+// counterpartyName is the name shown for the series, the description when the counterparty is unknown
+func counterpartyName(t *transactions.Transaction) string {
+	if t.Counterparty != nil && t.Counterparty.Name != "" {
+		return t.Counterparty.Name
+	}
+	if t.Counterparty != nil && t.Counterparty.IBAN != "" {
+		return t.Counterparty.IBAN
+	}
+	return t.Description
+}
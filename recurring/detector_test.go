@@ -0,0 +1,193 @@
+package recurring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+func TestDetectorSkipsCancelledTransactions(t *testing.T) {
+	start := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+	rent := func(id string, at time.Time, status transactions.Status) *transactions.Transaction {
+		return &transactions.Transaction{
+			ID:           id,
+			Type:         transactions.TypePayment,
+			Status:       status,
+			Amount:       900,
+			Currency:     "EUR",
+			CreationDate: at,
+			Counterparty: &transactions.Counterparty{Name: "Landlord"},
+		}
+	}
+
+	d := newDetector(0.05)
+	d.add([]*transactions.Transaction{
+		rent("jan", start, transactions.StatusPaid),
+		// a payment cancelled and made again a few days later
+		rent("feb-cancelled", start.AddDate(0, 1, -3), transactions.StatusCancelled),
+		rent("feb", start.AddDate(0, 1, 0), transactions.StatusPaid),
+		rent("mar", start.AddDate(0, 2, 0), transactions.StatusPaid),
+		rent("apr", start.AddDate(0, 3, 0), transactions.StatusPaid),
+	})
+
+	series := d.series(start.AddDate(0, 3, 10))
+	if len(series) != 1 {
+		t.Fatalf("expected a single series, got %d", len(series))
+	}
+	if series[0].Cadence != Monthly || series[0].Occurrences != 4 {
+		t.Errorf("expected 4 monthly occurrences, got %d %s: %v", series[0].Occurrences, series[0].Cadence, series[0].TransactionIDs)
+	}
+	for _, id := range series[0].TransactionIDs {
+		if id == "feb-cancelled" {
+			t.Errorf("expected the cancelled transaction to be left out, got %v", series[0].TransactionIDs)
+		}
+	}
+}
+
+// occurrences returns the paid payments to the counterparty at the dates, amounts defaulting to 100
+func occurrences(counterparty string, dates []time.Time, amounts []float64) []*transactions.Transaction {
+	list := make([]*transactions.Transaction, len(dates))
+	for i, at := range dates {
+		amount := 100.0
+		if amounts != nil {
+			amount = amounts[i]
+		}
+		list[i] = &transactions.Transaction{
+			ID:           at.Format("2006-01-02"),
+			Type:         transactions.TypePayment,
+			Status:       transactions.StatusPaid,
+			Amount:       amount,
+			Currency:     "EUR",
+			CreationDate: at,
+			Counterparty: &transactions.Counterparty{Name: counterparty},
+		}
+	}
+	return list
+}
+
+// every returns the count dates spaced by the months and days from the start
+func every(start time.Time, count, months, days int) []time.Time {
+	dates := make([]time.Time, count)
+	for i := range dates {
+		dates[i] = start.AddDate(0, i*months, i*days)
+	}
+	return dates
+}
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestDetectorFindsTheCadence(t *testing.T) {
+	tests := []struct {
+		name        string
+		dates       []time.Time
+		amounts     []float64
+		tolerance   float64
+		asOf        time.Time
+		cadence     Cadence
+		occurrences int
+		next        time.Time
+		stopped     bool
+	}{
+		{
+			name:  "weekly",
+			dates: every(day(2024, 1, 1), 5, 0, 7), asOf: day(2024, 2, 1),
+			cadence: Weekly, occurrences: 5, next: day(2024, 2, 5),
+		},
+		{
+			name:  "weekly, stopped past the date tolerance",
+			dates: every(day(2024, 1, 1), 5, 0, 7), asOf: day(2024, 2, 8),
+			cadence: Weekly, occurrences: 5, next: day(2024, 2, 5), stopped: true,
+		},
+		{
+			name:  "weekly, three occurrences are too few",
+			dates: every(day(2024, 1, 1), 3, 0, 7), asOf: day(2024, 1, 16),
+		},
+		{
+			name:  "yearly",
+			dates: every(day(2022, 3, 15), 3, 12, 0), asOf: day(2024, 6, 1),
+			cadence: Yearly, occurrences: 3, next: day(2025, 3, 15),
+		},
+		{
+			name:  "yearly, five days late within the tolerance",
+			dates: []time.Time{day(2023, 3, 15), day(2024, 3, 20)}, asOf: day(2025, 4, 1),
+			cadence: Yearly, occurrences: 2, next: day(2025, 3, 20),
+		},
+		{
+			name:  "yearly, stopped past the date tolerance",
+			dates: every(day(2022, 3, 15), 3, 12, 0), asOf: day(2025, 4, 1),
+			cadence: Yearly, occurrences: 3, next: day(2025, 3, 15), stopped: true,
+		},
+		{
+			name:  "monthly on the last day of the month",
+			dates: []time.Time{day(2024, 1, 31), day(2024, 2, 29), day(2024, 3, 31)}, asOf: day(2024, 4, 2),
+			cadence: Monthly, occurrences: 3, next: day(2024, 4, 30),
+		},
+		{
+			name:  "monthly, amounts within the tolerance",
+			dates: every(day(2024, 1, 5), 4, 1, 0), amounts: []float64{100, 108, 95, 104}, tolerance: 0.1, asOf: day(2024, 4, 10),
+			cadence: Monthly, occurrences: 4, next: day(2024, 5, 5),
+		},
+		{
+			name:  "monthly, an amount out of the tolerance is left out",
+			dates: every(day(2024, 1, 5), 4, 1, 0), amounts: []float64{100, 100, 100, 108}, asOf: day(2024, 3, 10),
+			cadence: Monthly, occurrences: 3, next: day(2024, 4, 5),
+		},
+		{
+			name:    "monthly, three intervals out of four matching",
+			dates:   []time.Time{day(2024, 1, 5), day(2024, 2, 5), day(2024, 3, 5), day(2024, 4, 5), day(2024, 6, 5)},
+			asOf:    day(2024, 6, 10),
+			cadence: Monthly, occurrences: 5, next: day(2024, 7, 5),
+		},
+		{
+			name:  "monthly, two intervals out of four matching",
+			dates: []time.Time{day(2024, 1, 5), day(2024, 2, 5), day(2024, 3, 5), day(2024, 5, 5), day(2024, 7, 5)},
+			asOf:  day(2024, 7, 10),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDetector(tt.tolerance)
+			d.add(occurrences("Gym", tt.dates, tt.amounts))
+			series := d.series(tt.asOf)
+
+			if tt.cadence == "" {
+				if len(series) != 0 {
+					t.Fatalf("expected no series, got %s with %v", series[0].Cadence, series[0].TransactionIDs)
+				}
+				return
+			}
+			if len(series) != 1 {
+				t.Fatalf("expected a single series, got %d", len(series))
+			}
+			got := series[0]
+			if got.Cadence != tt.cadence || got.Occurrences != tt.occurrences {
+				t.Errorf("expected %d %s occurrences, got %d %s: %v", tt.occurrences, tt.cadence, got.Occurrences, got.Cadence, got.TransactionIDs)
+			}
+			if !got.NextExpectedDate.Equal(tt.next) || got.Stopped != tt.stopped {
+				t.Errorf("expected the next date %s, stopped %v, got %s, stopped %v", tt.next, tt.stopped, got.NextExpectedDate, got.Stopped)
+			}
+		})
+	}
+}
+
+func TestNextMonthClampsToTheLastDay(t *testing.T) {
+	tests := []struct {
+		from, want time.Time
+	}{
+		{day(2024, 1, 15), day(2024, 2, 15)},
+		{day(2024, 1, 31), day(2024, 2, 29)},
+		{day(2023, 1, 31), day(2023, 2, 28)},
+		{day(2024, 3, 31), day(2024, 4, 30)},
+		{day(2024, 12, 31), day(2025, 1, 31)},
+	}
+
+	for _, tt := range tests {
+		if got := nextMonth(tt.from); !got.Equal(tt.want) {
+			t.Errorf("nextMonth(%s) = %s, want %s", tt.from.Format("2006-01-02"), got.Format("2006-01-02"), tt.want.Format("2006-01-02"))
+		}
+	}
+}
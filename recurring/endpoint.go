@@ -0,0 +1,25 @@
+package recurring
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// Endpoints represents all endpoints
+type Endpoints struct {
+	DetectEndpoint endpoint.Endpoint
+}
+
+func MakeEndpoints(s Service) Endpoints {
+	return Endpoints{
+		DetectEndpoint: makeDetectEndpoint(s),
+	}
+}
+
+func makeDetectEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(Request)
+		return s.Detect(ctx, req)
+	}
+}
@@ -0,0 +1,69 @@
+package recurring
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+	"github.com/fsilberstein/parameters-issue/transactions"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+)
+
+// MakeHTTPHandler ...
+func MakeHTTPHandler(endpoints Endpoints, router *mux.Router) http.Handler {
+
+	options := []kithttp.ServerOption{
+		kithttp.ServerBefore(kithttp.PopulateRequestContext),
+		kithttp.ServerErrorEncoder(errors.LoggingErrorEncoder),
+	}
+
+	detectHandler := kithttp.NewServer(
+		endpoints.DetectEndpoint,
+		decodeDetectRequest,
+		encodeResponse,
+		options...,
+	)
+
+	ur := router.PathPrefix("/users").Subrouter().StrictSlash(true)
+	{
+		ur.Handle("/{id}/recurring", detectHandler).Methods("GET")
+	}
+
+	return router
+}
+
+// decodeDetectRequest accepts the filters of the transaction listings and `amount_tolerance`
+func decodeDetectRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+	if !ok {
+		return nil, errors.NewInvalidArgument("invalid parameter 'user_id'")
+	}
+
+	request := Request{Query: transactions.NewTransactionQuery()}
+	request.Query.UserID = &id
+
+	params := r.URL.Query()
+	if err := transactions.DecodeFilters(params, &request.Query); err != nil {
+		return nil, err
+	}
+
+	amountTolerance, ok := params["amount_tolerance"]
+	if ok && len(amountTolerance) > 0 {
+		f, err := strconv.ParseFloat(amountTolerance[0], 64)
+		if err != nil {
+			return nil, errors.NewInvalidArgument("invalid parameter 'amount_tolerance'")
+		}
+		request.AmountTolerance = &f
+	}
+
+	return request, nil
+}
+
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,105 @@
+package recurring
+
+import (
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+const (
+	// defaultHistory is the history analyzed when the request sets no date range, back from now. It holds two
+	// occurrences of a yearly series at least.
+	defaultHistory = 2 * 366 * 24 * time.Hour
+	// maxHistory bounds the history analyzed, its transactions are kept in memory
+	maxHistory = 5 * 366 * 24 * time.Hour
+	// defaultAmountTolerance is the relative difference accepted between the amounts of a series
+	defaultAmountTolerance = 0.1
+)
+
+// Cadence is the period of a series
+type Cadence string
+
+// All the cadences
+const (
+	Weekly  Cadence = "weekly"
+	Monthly Cadence = "monthly"
+	Yearly  Cadence = "yearly"
+)
+
+// cadenceSpec describes how a cadence is detected: most of the intervals between the occurrences of a series
+// are Days long, give or take Tolerance days
+type cadenceSpec struct {
+	Cadence        Cadence
+	Days           float64
+	Tolerance      float64
+	MinOccurrences int
+	// next returns the date expected after an occurrence
+	next func(t time.Time) time.Time
+}
+
+// cadences are tried in order, the first one matching wins
+var cadences = []cadenceSpec{
+	{Weekly, 7, 2, 4, func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }},
+	{Monthly, 30.44, 4, 3, nextMonth},
+	{Yearly, 365.25, 15, 2, func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+}
+
+// nextMonth returns the same day of the next month, or its last day when shorter
+func nextMonth(t time.Time) time.Time {
+	next := t.AddDate(0, 1, 0)
+	if next.Day() != t.Day() {
+		// AddDate overflowed into the month after, going back that many days lands on the last day
+		next = next.AddDate(0, 0, -next.Day())
+	}
+	return next
+}
+
+// Series is a set of transactions with the same counterparty and similar amounts, happening at a regular
+// cadence. AverageAmount is signed, negative when money goes out. A series is stopped when its next expected
+// date is over by more than the date tolerance of its cadence.
+type Series struct {
+	Counterparty     string    `json:"counterparty"`
+	Description      string    `json:"description,omitempty"`
+	Currency         string    `json:"currency"`
+	Cadence          Cadence   `json:"cadence"`
+	Occurrences      int       `json:"occurrences"`
+	AverageAmount    float64   `json:"average_amount"`
+	FirstDate        time.Time `json:"first_date"`
+	LastDate         time.Time `json:"last_date"`
+	NextExpectedDate time.Time `json:"next_expected_date"`
+	Stopped          bool      `json:"stopped"`
+	TransactionIDs   []string  `json:"transaction_ids"`
+}
+
+// Request asks for the recurring series among the transactions matching Query, Query holding the user.
+// AmountTolerance is the relative difference accepted between the amounts of a series, 0.1 by default.
+type Request struct {
+	Query           transactions.TransactionQuery `json:"query"`
+	AmountTolerance *float64                      `json:"amount_tolerance"`
+}
+
+// Validate checks the tolerance and the query, whose date range must be set
+func (r Request) Validate() error {
+	if r.Query.UserID == nil || *r.Query.UserID == "" {
+		return errors.NewInvalidArgument("invalid parameter 'user_id'")
+	}
+	if r.Query.DateFrom == nil || r.Query.DateTo == nil || r.Query.DateTo.Sub(*r.Query.DateFrom) > maxHistory {
+		return errors.NewInvalidArgument("the date range can not span more than five years")
+	}
+	if len(r.Query.Category) > 0 {
+		// categories are resolved by the transactions service, the analysis reads the repository
+		return errors.NewInvalidArgument("parameter 'category' is not supported")
+	}
+	if r.AmountTolerance != nil && (*r.AmountTolerance < 0 || *r.AmountTolerance > 1) {
+		return errors.NewInvalidArgument("parameter 'amount_tolerance' must be between 0 and 1")
+	}
+	return r.Query.Validate()
+}
+
+// Report lists the series found in the history up to AsOf: the active ones by next expected date, then the
+// stopped ones, most recent first
+type Report struct {
+	AsOf   time.Time `json:"as_of"`
+	Series []*Series `json:"series"`
+}
@@ -0,0 +1,68 @@
+package recurring
+
+import (
+	"context"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+// Service is the recurring service interface
+type Service interface {
+	// Detect analyzes the history of a user and reports the recurring series of transactions
+	Detect(ctx context.Context, request Request) (*Report, error)
+}
+
+type service struct {
+	repo transactions.Repository
+}
+
+// NewService initializes new service, the history is read from the transactions repository
+func NewService(repo transactions.Repository) (Service, error) {
+	return &service{
+		repo: repo,
+	}, nil
+}
+
+// Detect analyzes the last years up to now when the request sets no date range, see defaultHistory. Merged
+// duplicates are left out, they would look like extra occurrences.
+func (s *service) Detect(ctx context.Context, request Request) (*Report, error) {
+	now := time.Now()
+	if request.Query.DateTo == nil {
+		request.Query.DateTo = &now
+	}
+	if request.Query.DateFrom == nil {
+		from := request.Query.DateTo.Add(-defaultHistory)
+		request.Query.DateFrom = &from
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	amountTolerance := defaultAmountTolerance
+	if request.AmountTolerance != nil {
+		amountTolerance = *request.AmountTolerance
+	}
+
+	query := request.Query
+	query.HideDuplicates = true
+	d := newDetector(amountTolerance)
+	err := s.repo.Stream(ctx, query, func(page []*transactions.Transaction) error {
+		d.add(page)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// a series is not stopped by the end of a date range in the past
+	asOf := now
+	if request.Query.DateTo.Before(asOf) {
+		asOf = *request.Query.DateTo
+	}
+	series := d.series(asOf)
+	if series == nil {
+		series = []*Series{}
+	}
+	return &Report{AsOf: asOf, Series: series}, nil
+}
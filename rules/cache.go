@@ -0,0 +1,94 @@
+package rules
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxCachedUsers bounds the number of users whose rules are cached
+const maxCachedUsers = 10000
+
+type cachedRules struct {
+	rules      []*Rule
+	set        ruleset
+	expiration time.Time
+}
+
+// cachingRepository keeps the rules of the users in memory for ttl, compiled, so that the transactions listed do
+// not each cost a lookup and a compilation. The rules of a user are read again once one of them is created or
+// deleted through this repository, the changes made by other instances are seen after ttl at most.
+type cachingRepository struct {
+	Repository
+	ttl time.Duration
+
+	mu    sync.Mutex
+	users map[string]cachedRules
+}
+
+// NewCachingRepository decorates the repository with a cache of the rules by user
+func NewCachingRepository(repo Repository, ttl time.Duration) Repository {
+	return &cachingRepository{Repository: repo, ttl: ttl, users: make(map[string]cachedRules)}
+}
+
+func (r *cachingRepository) get(ctx context.Context, userID string) (cachedRules, error) {
+	now := time.Now()
+	r.mu.Lock()
+	cached, ok := r.users[userID]
+	r.mu.Unlock()
+	if ok && now.Before(cached.expiration) {
+		return cached, nil
+	}
+
+	list, err := r.Repository.GetByUser(ctx, userID)
+	if err != nil {
+		return cachedRules{}, err
+	}
+	cached = cachedRules{rules: list, set: compile(list), expiration: now.Add(r.ttl)}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.users) >= maxCachedUsers {
+		for id, c := range r.users {
+			if !now.Before(c.expiration) {
+				delete(r.users, id)
+			}
+		}
+		if len(r.users) >= maxCachedUsers {
+			r.users = make(map[string]cachedRules)
+		}
+	}
+	r.users[userID] = cached
+	return cached, nil
+}
+
+// GetByUser returns the cached rules, which must not be modified
+func (r *cachingRepository) GetByUser(ctx context.Context, userID string) ([]*Rule, error) {
+	cached, err := r.get(ctx, userID)
+	return cached.rules, err
+}
+
+// ruleset returns the cached rules compiled
+func (r *cachingRepository) ruleset(ctx context.Context, userID string) (ruleset, error) {
+	cached, err := r.get(ctx, userID)
+	return cached.set, err
+}
+
+func (r *cachingRepository) Create(ctx context.Context, rule *Rule) error {
+	defer r.forget(rule.UserID)
+	return r.Repository.Create(ctx, rule)
+}
+
+func (r *cachingRepository) Delete(ctx context.Context, ruleID string) error {
+	rule, err := r.Repository.Get(ctx, ruleID)
+	if err == nil {
+		defer r.forget(rule.UserID)
+	}
+	return r.Repository.Delete(ctx, ruleID)
+}
+
+func (r *cachingRepository) forget(userID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.users, userID)
+}
@@ -0,0 +1,185 @@
+package rules
+
+import (
+	"context"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+// categorizingService decorates the transactions service: the `category` filter of the queries is turned into
+// a filter expression from the rules of the user, and the transactions returned, listed, streamed or watched,
+// get their category
+type categorizingService struct {
+	transactions.Service
+	repo Repository
+}
+
+// NewCategorizingService decorates the transactions service with the rules of the repository
+func NewCategorizingService(next transactions.Service, repo Repository) transactions.Service {
+	return &categorizingService{Service: next, repo: repo}
+}
+
+// rulesetSource is implemented by the repositories keeping the rules compiled, see NewCachingRepository
+type rulesetSource interface {
+	ruleset(ctx context.Context, userID string) (ruleset, error)
+}
+
+func (s *categorizingService) ruleset(ctx context.Context, userID string) (ruleset, error) {
+	if source, ok := s.repo.(rulesetSource); ok {
+		return source.ruleset(ctx, userID)
+	}
+	list, err := s.repo.GetByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return compile(list), nil
+}
+
+// categorize sets the category of the transactions, which may belong to several users
+func (s *categorizingService) categorize(ctx context.Context, list []*transactions.Transaction) error {
+	byUser := make(map[string][]*transactions.Transaction)
+	for _, t := range list {
+		byUser[t.UserID] = append(byUser[t.UserID], t)
+	}
+	for userID, userList := range byUser {
+		set, err := s.ruleset(ctx, userID)
+		if err != nil {
+			return err
+		}
+		set.apply(userList)
+	}
+	return nil
+}
+
+// resolve replaces the categories of the query by the matching filter expression
+func (s *categorizingService) resolve(ctx context.Context, query *transactions.TransactionQuery) error {
+	if len(query.Category) == 0 {
+		return nil
+	}
+	if query.UserID == nil {
+		return errors.NewInvalidArgument("parameter 'category' can only be used on the transactions of a user")
+	}
+
+	set, err := s.ruleset(ctx, *query.UserID)
+	if err != nil {
+		return err
+	}
+	set.resolve(query)
+	return nil
+}
+
+func (s *categorizingService) GetByUser(ctx context.Context, query transactions.TransactionQuery) (*transactions.TransactionPage, error) {
+	if query.UserID == nil {
+		return nil, errors.NewInvalidArgument("invalid parameter 'user_id'")
+	}
+
+	set, err := s.ruleset(ctx, *query.UserID)
+	if err != nil {
+		return nil, err
+	}
+	set.resolve(&query)
+
+	page, err := s.Service.GetByUser(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	set.apply(page.Transactions)
+	return page, nil
+}
+
+func (s *categorizingService) GetByID(ctx context.Context, request transactions.TransactionRequest) (*transactions.Transaction, error) {
+	transaction, err := s.Service.GetByID(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := s.ruleset(ctx, transaction.UserID)
+	if err != nil {
+		return nil, err
+	}
+	set.apply([]*transactions.Transaction{transaction})
+	return transaction, nil
+}
+
+func (s *categorizingService) Update(ctx context.Context, request transactions.UpdateRequest) (*transactions.Transaction, error) {
+	transaction, err := s.Service.Update(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := s.ruleset(ctx, transaction.UserID)
+	if err != nil {
+		return nil, err
+	}
+	set.apply([]*transactions.Transaction{transaction})
+	return transaction, nil
+}
+
+func (s *categorizingService) GetByDateRange(ctx context.Context, query transactions.TransactionQuery) ([]*transactions.Transaction, int64, error) {
+	if err := s.resolve(ctx, &query); err != nil {
+		return nil, 0, err
+	}
+	list, total, err := s.Service.GetByDateRange(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := s.categorize(ctx, list); err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+func (s *categorizingService) GetSummaryByUser(ctx context.Context, request transactions.SummaryRequest) (*transactions.TransactionsSummary, error) {
+	if err := s.resolve(ctx, &request.Query); err != nil {
+		return nil, err
+	}
+	return s.Service.GetSummaryByUser(ctx, request)
+}
+
+func (s *categorizingService) Stream(ctx context.Context, query transactions.TransactionQuery, fn func([]*transactions.Transaction) error) error {
+	if err := s.resolve(ctx, &query); err != nil {
+		return err
+	}
+	return s.Service.Stream(ctx, query, func(list []*transactions.Transaction) error {
+		if err := s.categorize(ctx, list); err != nil {
+			return err
+		}
+		return fn(list)
+	})
+}
+
+func (s *categorizingService) Export(ctx context.Context, request transactions.ExportRequest, fn func([]*transactions.Transaction) error) error {
+	if err := s.resolve(ctx, &request.Query); err != nil {
+		return err
+	}
+	return s.Service.Export(ctx, request, func(list []*transactions.Transaction) error {
+		if err := s.categorize(ctx, list); err != nil {
+			return err
+		}
+		return fn(list)
+	})
+}
+
+func (s *categorizingService) GetStatement(ctx context.Context, query transactions.TransactionQuery) (*transactions.Statement, error) {
+	if err := s.resolve(ctx, &query); err != nil {
+		return nil, err
+	}
+	return s.Service.GetStatement(ctx, query)
+}
+
+func (s *categorizingService) Watch(ctx context.Context, request transactions.WatchRequest, fn func([]*transactions.Change) error) error {
+	if err := s.resolve(ctx, &request.Query); err != nil {
+		return err
+	}
+	return s.Service.Watch(ctx, request, func(changes []*transactions.Change) error {
+		list := make([]*transactions.Transaction, len(changes))
+		for i, change := range changes {
+			list[i] = change.Transaction
+		}
+		if err := s.categorize(ctx, list); err != nil {
+			return err
+		}
+		return fn(changes)
+	})
+}
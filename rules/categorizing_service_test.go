@@ -0,0 +1,153 @@
+package rules
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+// memoryRepository keeps the rules, counting the lookups by user
+type memoryRepository struct {
+	mu      sync.Mutex
+	rules   []*Rule
+	lookups int
+}
+
+func (r *memoryRepository) Create(ctx context.Context, rule *Rule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules = append(r.rules, rule)
+	return nil
+}
+
+func (r *memoryRepository) Get(ctx context.Context, ruleID string) (*Rule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rule := range r.rules {
+		if rule.ID == ruleID {
+			return rule, nil
+		}
+	}
+	return nil, errors.NewNotFoundError("rule")
+}
+
+func (r *memoryRepository) GetByUser(ctx context.Context, userID string) ([]*Rule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups++
+	var result []*Rule
+	for _, rule := range r.rules {
+		if rule.UserID == userID {
+			result = append(result, rule)
+		}
+	}
+	return result, nil
+}
+
+func (r *memoryRepository) Delete(ctx context.Context, ruleID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, rule := range r.rules {
+		if rule.ID == ruleID {
+			r.rules = append(r.rules[:i], r.rules[i+1:]...)
+		}
+	}
+	return nil
+}
+
+// listingService returns new copies of the same transactions whatever the query
+type listingService struct {
+	transactions.Service
+	list func() []*transactions.Transaction
+}
+
+func (s *listingService) Stream(ctx context.Context, query transactions.TransactionQuery, fn func([]*transactions.Transaction) error) error {
+	return fn(s.list())
+}
+
+func (s *listingService) Export(ctx context.Context, request transactions.ExportRequest, fn func([]*transactions.Transaction) error) error {
+	return fn(s.list())
+}
+
+func (s *listingService) Watch(ctx context.Context, request transactions.WatchRequest, fn func([]*transactions.Change) error) error {
+	var changes []*transactions.Change
+	for _, t := range s.list() {
+		changes = append(changes, &transactions.Change{Transaction: t})
+	}
+	return fn(changes)
+}
+
+func TestCategorizingServiceCategorizesEveryResult(t *testing.T) {
+	repo := &memoryRepository{rules: []*Rule{
+		{ID: "r1", UserID: "u1", Category: "Rent", Condition: `counterparty.name ~ landlord`, CreationDate: time.Now()},
+		{ID: "r2", UserID: "u2", Category: "SaaS", Condition: `amount < 100`, CreationDate: time.Now()},
+	}}
+	next := &listingService{list: func() []*transactions.Transaction {
+		return []*transactions.Transaction{
+			{ID: "t1", UserID: "u1", Amount: 900, Counterparty: &transactions.Counterparty{Name: "The Landlord"}},
+			{ID: "t2", UserID: "u2", Amount: 20},
+			{ID: "t3", UserID: "u1", Amount: 20, Category: "Food"},
+		}
+	}}
+	service := NewCategorizingService(next, NewCachingRepository(repo, time.Hour))
+	ctx := context.Background()
+	userID := "u1"
+	query := transactions.NewTransactionQuery()
+	query.UserID = &userID
+
+	check := func(name string, list []*transactions.Transaction) {
+		categories := map[string]string{}
+		for _, t := range list {
+			categories[t.ID] = t.Category
+		}
+		if categories["t1"] != "Rent" || categories["t2"] != "SaaS" || categories["t3"] != "Food" {
+			t.Errorf("%s: expected the transactions to be categorized by the rules of their user, got %v", name, categories)
+		}
+	}
+
+	service.Stream(ctx, query, func(list []*transactions.Transaction) error {
+		check("stream", list)
+		return nil
+	})
+	service.Export(ctx, transactions.ExportRequest{Query: query}, func(list []*transactions.Transaction) error {
+		check("export", list)
+		return nil
+	})
+	service.Watch(ctx, transactions.WatchRequest{Query: query}, func(changes []*transactions.Change) error {
+		var list []*transactions.Transaction
+		for _, change := range changes {
+			list = append(list, change.Transaction)
+		}
+		check("watch", list)
+		return nil
+	})
+
+	if repo.lookups != 2 {
+		t.Errorf("expected the rules of each user to be read once, got %d lookups", repo.lookups)
+	}
+}
+
+func TestCachingRepositoryForgetsTheRulesOfAUserOnChange(t *testing.T) {
+	repo := &memoryRepository{}
+	cache := NewCachingRepository(repo, time.Hour).(*cachingRepository)
+	ctx := context.Background()
+
+	if set, _ := cache.ruleset(ctx, "u1"); len(set) != 0 {
+		t.Fatalf("expected no rule, got %d", len(set))
+	}
+	cache.Create(ctx, &Rule{ID: "r1", UserID: "u1", Category: "Rent", Condition: `amount > 500`})
+	if set, _ := cache.ruleset(ctx, "u1"); len(set) != 1 {
+		t.Errorf("expected the created rule, got %d rules", len(set))
+	}
+	cache.Delete(ctx, "r1")
+	if set, _ := cache.ruleset(ctx, "u1"); len(set) != 0 {
+		t.Errorf("expected the deleted rule to be gone, got %d rules", len(set))
+	}
+	if repo.lookups != 3 {
+		t.Errorf("expected a lookup after each change only, got %d", repo.lookups)
+	}
+}
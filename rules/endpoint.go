@@ -0,0 +1,52 @@
+package rules
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// Endpoints represents all endpoints
+type Endpoints struct {
+	CreateEndpoint    endpoint.Endpoint
+	GetByUserEndpoint endpoint.Endpoint
+	DeleteEndpoint    endpoint.Endpoint
+	DryRunEndpoint    endpoint.Endpoint
+}
+
+func MakeEndpoints(s Service) Endpoints {
+	return Endpoints{
+		CreateEndpoint:    makeCreateEndpoint(s),
+		GetByUserEndpoint: makeGetByUserEndpoint(s),
+		DeleteEndpoint:    makeDeleteEndpoint(s),
+		DryRunEndpoint:    makeDryRunEndpoint(s),
+	}
+}
+
+func makeCreateEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RuleRequest)
+		return s.Create(ctx, req)
+	}
+}
+
+func makeGetByUserEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		userID := request.(string)
+		return s.GetByUser(ctx, userID)
+	}
+}
+
+func makeDeleteEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RuleID)
+		return nil, s.Delete(ctx, req)
+	}
+}
+
+func makeDryRunEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RuleRequest)
+		return s.DryRun(ctx, req)
+	}
+}
@@ -0,0 +1,115 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+)
+
+// MakeHTTPHandler ...
+func MakeHTTPHandler(endpoints Endpoints, router *mux.Router) http.Handler {
+
+	options := []kithttp.ServerOption{
+		kithttp.ServerBefore(kithttp.PopulateRequestContext),
+		kithttp.ServerErrorEncoder(errors.LoggingErrorEncoder),
+	}
+
+	createHandler := kithttp.NewServer(
+		endpoints.CreateEndpoint,
+		decodeRuleRequest,
+		encodeResponse(http.StatusCreated),
+		options...,
+	)
+
+	getByUserHandler := kithttp.NewServer(
+		endpoints.GetByUserEndpoint,
+		decodeGetByUserRequest,
+		encodeResponse(http.StatusOK),
+		options...,
+	)
+
+	deleteHandler := kithttp.NewServer(
+		endpoints.DeleteEndpoint,
+		decodeRuleID,
+		encodeNoContent,
+		options...,
+	)
+
+	dryRunHandler := kithttp.NewServer(
+		endpoints.DryRunEndpoint,
+		decodeRuleRequest,
+		encodeResponse(http.StatusOK),
+		options...,
+	)
+
+	ur := router.PathPrefix("/users").Subrouter().StrictSlash(true)
+	{
+		ur.Handle("/{id}/rules/", createHandler).Methods("POST")
+		ur.Handle("/{id}/rules/", getByUserHandler).Methods("GET")
+		ur.Handle("/{id}/rules/_dry_run", dryRunHandler).Methods("POST")
+		ur.Handle("/{id}/rules/{ruleID}", deleteHandler).Methods("DELETE")
+	}
+
+	return router
+}
+
+func decodeRuleRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+	if !ok {
+		return nil, errors.NewInvalidArgument("invalid parameter 'user_id'")
+	}
+
+	var request RuleRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		return nil, errors.NewInvalidArgument("could not decode the rule: " + err.Error())
+	}
+	request.UserID = id
+
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+func decodeGetByUserRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+	if !ok {
+		return nil, errors.NewInvalidArgument("invalid parameter 'user_id'")
+	}
+	return id, nil
+}
+
+func decodeRuleID(ctx context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+	if !ok {
+		return nil, errors.NewInvalidArgument("invalid parameter 'user_id'")
+	}
+	ruleID, ok := vars["ruleID"]
+	if !ok {
+		return nil, errors.NewInvalidArgument("invalid parameter 'rule_id'")
+	}
+	return RuleID{UserID: id, RuleID: ruleID}, nil
+}
+
+func encodeResponse(status int) kithttp.EncodeResponseFunc {
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		return json.NewEncoder(w).Encode(response)
+	}
+}
+
+func encodeNoContent(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
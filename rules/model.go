@@ -0,0 +1,196 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+	"github.com/fsilberstein/parameters-issue/logger"
+	"github.com/fsilberstein/parameters-issue/transactions"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxRulesByUser bounds the number of rules of a user, all of them being evaluated for every transaction
+	maxRulesByUser = 200
+	// maxCategoryLength bounds the length of a category
+	maxCategoryLength = 64
+)
+
+// Rule gives its category to the transactions matching its condition, a filter expression like
+// `counterparty.name~"acme" AND amount>100` (see transactions.ParseFilter). Rules are evaluated by ascending
+// priority, then by creation date, and the first matching rule wins.
+type Rule struct {
+	ID           string    `json:"id"`
+	UserID       string    `json:"user_id"`
+	Category     string    `json:"category"`
+	Condition    string    `json:"condition"`
+	Priority     int       `json:"priority"`
+	CreationDate time.Time `json:"creation_date"`
+}
+
+// RuleRequest asks for the creation of a rule
+type RuleRequest struct {
+	UserID    string `json:"-"`
+	Category  string `json:"category"`
+	Condition string `json:"condition"`
+	Priority  int    `json:"priority"`
+}
+
+// Validate checks the category and parses the condition
+func (r RuleRequest) Validate() error {
+	if r.Category == "" || len(r.Category) > maxCategoryLength {
+		return errors.NewInvalidArgument(fmt.Sprintf("field 'category' is mandatory and at most %d characters long", maxCategoryLength))
+	}
+	_, err := transactions.ParseFilterExpr("condition", r.Condition)
+	return err
+}
+
+// RuleID identifies a rule of a user
+type RuleID struct {
+	UserID string `json:"user_id"`
+	RuleID string `json:"rule_id"`
+}
+
+// DryRunMatch is a past transaction matching the condition of a rule being tried, Category being the category
+// it would get with the rule, which differs when a rule evaluated before matches it too
+type DryRunMatch struct {
+	Transaction     *transactions.Transaction `json:"transaction"`
+	CurrentCategory string                    `json:"current_category,omitempty"`
+	Category        string                    `json:"category"`
+}
+
+// DryRunResult lists the first past transactions matching the condition, most recent first
+type DryRunResult struct {
+	Total   int64          `json:"total"`
+	Matches []*DryRunMatch `json:"matches"`
+}
+
+type compiledRule struct {
+	*Rule
+	condition transactions.FilterExpr
+}
+
+// ruleset holds the rules of a user in evaluation order
+type ruleset []*compiledRule
+
+// compile parses the conditions and orders the rules. A stored condition which no longer parses is skipped.
+func compile(rules []*Rule) ruleset {
+	set := make(ruleset, 0, len(rules))
+	for _, rule := range rules {
+		condition, err := transactions.ParseFilterExpr("condition", rule.Condition)
+		if err != nil {
+			logger.LogStdErr.Error("invalid rule skipped", zap.String("rule", rule.ID), zap.Error(err))
+			continue
+		}
+		set = append(set, &compiledRule{Rule: rule, condition: condition})
+	}
+
+	sort.SliceStable(set, func(i, j int) bool {
+		if set[i].Priority != set[j].Priority {
+			return set[i].Priority < set[j].Priority
+		}
+		return set[i].CreationDate.Before(set[j].CreationDate)
+	})
+	return set
+}
+
+// match returns the first rule matching the transaction, nil when none does
+func (set ruleset) match(t *transactions.Transaction) *compiledRule {
+	for _, rule := range set {
+		if transactions.MatchFilter(rule.condition, t) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// isManual tells whether the category of the transaction was set by the user, the rules do not override it
+func isManual(t *transactions.Transaction) bool {
+	return t.Category != "" && t.CategoryRule == ""
+}
+
+// categorize returns the category set by the user, or else the category of the first matching rule
+func (set ruleset) categorize(t *transactions.Transaction) string {
+	if isManual(t) {
+		return t.Category
+	}
+	if rule := set.match(t); rule != nil {
+		return rule.Category
+	}
+	return ""
+}
+
+// apply sets the category of the transactions which were not categorized by the user
+func (set ruleset) apply(list []*transactions.Transaction) {
+	for _, t := range list {
+		if isManual(t) {
+			continue
+		}
+		t.Category, t.CategoryRule = "", ""
+		if rule := set.match(t); rule != nil {
+			t.Category, t.CategoryRule = rule.Category, rule.ID
+		}
+	}
+}
+
+// categoryFilter builds the filter expression matching the transactions categorized as one of the categories:
+// a transaction has the category stored when set by the user, or else gets the category of a rule when it
+// matches the rule and none of the rules before it
+func (set ruleset) categoryFilter(categories []string) transactions.FilterExpr {
+	wanted := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		wanted[category] = true
+	}
+
+	var operands []transactions.FilterExpr
+	var before []transactions.FilterExpr
+	for _, rule := range set {
+		if wanted[rule.Category] {
+			expr := rule.condition
+			if len(before) > 0 {
+				expr = transactions.FilterAnd{Operands: []transactions.FilterExpr{
+					rule.condition,
+					transactions.FilterNot{Operand: transactions.FilterOr{Operands: append([]transactions.FilterExpr(nil), before...)}},
+				}}
+			}
+			operands = append(operands, expr)
+		}
+		before = append(before, rule.condition)
+	}
+
+	var byRules transactions.FilterExpr
+	switch len(operands) {
+	case 0:
+		// no rule gives any of the categories, no transaction can match them
+		byRules = transactions.FilterNone{}
+	case 1:
+		byRules = operands[0]
+	default:
+		byRules = transactions.FilterOr{Operands: operands}
+	}
+
+	manual := make([]transactions.FilterExpr, 0, len(categories)+1)
+	for _, category := range categories {
+		manual = append(manual, transactions.FilterComparison{Field: "category", Operator: transactions.FilterEqual, Value: category})
+	}
+	return transactions.FilterOr{Operands: append(manual, transactions.FilterAnd{Operands: []transactions.FilterExpr{
+		transactions.FilterNot{Operand: transactions.FilterExists{Field: "category"}},
+		byRules,
+	}})}
+}
+
+// resolve replaces the categories of the query by the matching filter expression
+func (set ruleset) resolve(query *transactions.TransactionQuery) {
+	if len(query.Category) == 0 {
+		return
+	}
+
+	expr := set.categoryFilter(query.Category)
+	if query.Filter != nil {
+		expr = transactions.FilterAnd{Operands: []transactions.FilterExpr{query.Filter, expr}}
+	}
+	query.Filter = expr
+	query.Category = nil
+}
@@ -0,0 +1,11 @@
+package rules
+
+import "context"
+
+// Repository persists the rules
+type Repository interface {
+	Create(ctx context.Context, rule *Rule) error
+	Get(ctx context.Context, ruleID string) (*Rule, error)
+	GetByUser(ctx context.Context, userID string) ([]*Rule, error)
+	Delete(ctx context.Context, ruleID string) error
+}
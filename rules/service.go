@@ -0,0 +1,139 @@
+package rules
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+// maxDryRunMatches is the number of matching transactions returned by a dry run
+const maxDryRunMatches = 100
+
+// Service is the rule service interface
+type Service interface {
+	Create(ctx context.Context, request RuleRequest) (*Rule, error)
+	GetByUser(ctx context.Context, userID string) ([]*Rule, error)
+	Delete(ctx context.Context, id RuleID) error
+	// DryRun lists the past transactions the rule would match, without storing it
+	DryRun(ctx context.Context, request RuleRequest) (*DryRunResult, error)
+}
+
+type service struct {
+	repo         Repository
+	transactions transactions.Service
+}
+
+// NewService initializes new service, dry runs search the transactions through transactionsService
+func NewService(repo Repository, transactionsService transactions.Service) (Service, error) {
+	return &service{
+		repo:         repo,
+		transactions: transactionsService,
+	}, nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *service) Create(ctx context.Context, request RuleRequest) (*Rule, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.GetByUser(ctx, request.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxRulesByUser {
+		return nil, errors.NewInvalidArgument(fmt.Sprintf("a user can not have more than %d rules", maxRulesByUser))
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &Rule{
+		ID:           id,
+		UserID:       request.UserID,
+		Category:     request.Category,
+		Condition:    request.Condition,
+		Priority:     request.Priority,
+		CreationDate: time.Now(),
+	}
+	if err := s.repo.Create(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// GetByUser returns the rules in evaluation order
+func (s *service) GetByUser(ctx context.Context, userID string) ([]*Rule, error) {
+	list, err := s.repo.GetByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*Rule{}
+	for _, rule := range compile(list) {
+		result = append(result, rule.Rule)
+	}
+	return result, nil
+}
+
+func (s *service) Delete(ctx context.Context, id RuleID) error {
+	rule, err := s.repo.Get(ctx, id.RuleID)
+	if err != nil {
+		return err
+	}
+	if rule.UserID != id.UserID {
+		return errors.NewNotFoundError("rule")
+	}
+	return s.repo.Delete(ctx, id.RuleID)
+}
+
+// DryRun searches the transactions matching the condition, and tells the category each of them would get once
+// the rule is added to the existing ones
+func (s *service) DryRun(ctx context.Context, request RuleRequest) (*DryRunResult, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	condition, _ := transactions.ParseFilterExpr("condition", request.Condition)
+
+	existing, err := s.repo.GetByUser(ctx, request.UserID)
+	if err != nil {
+		return nil, err
+	}
+	candidate := &Rule{UserID: request.UserID, Category: request.Category, Condition: request.Condition, Priority: request.Priority, CreationDate: time.Now()}
+	// the rules may be shared with a cache, the candidate is appended to a copy
+	current, withCandidate := compile(existing), compile(append(existing[:len(existing):len(existing)], candidate))
+
+	query := transactions.NewTransactionQuery()
+	query.UserID = &request.UserID
+	query.Filter = condition
+	query.PageSize = maxDryRunMatches
+
+	page, err := s.transactions.GetByUser(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DryRunResult{Total: page.Total, Matches: []*DryRunMatch{}}
+	for _, t := range page.Transactions {
+		result.Matches = append(result.Matches, &DryRunMatch{
+			Transaction:     t,
+			CurrentCategory: current.categorize(t),
+			Category:        withCandidate.categorize(t),
+		})
+	}
+	return result, nil
+}
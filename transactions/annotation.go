@@ -0,0 +1,77 @@
+package transactions
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+)
+
+const (
+	maxTags           = 20
+	maxTagLength      = 64
+	maxNoteLength     = 1000
+	maxCategoryLength = 64
+)
+
+// TransactionPatch holds the fields a user can edit. A nil field is left unchanged, an empty one is cleared.
+// A category set by the user replaces the one given by the rules.
+type TransactionPatch struct {
+	Tags     *[]string `json:"tags"`
+	Note     *string   `json:"note"`
+	Category *string   `json:"category"`
+	// DuplicateOf marks the transaction as merged into another one, or unmerges it when empty. It is only set by
+	// the duplicates package, never by a request.
+	DuplicateOf *string `json:"-"`
+}
+
+// UpdateRequest asks to patch a transaction of a user, Version being the version the changes were made on
+type UpdateRequest struct {
+	UserID        string           `json:"user_id"`
+	TransactionID string           `json:"transaction_id"`
+	Version       int64            `json:"version"`
+	Patch         TransactionPatch `json:"patch"`
+}
+
+// Validate checks the patch, tags are expected to be normalized already, see NormalizeTags
+func (r UpdateRequest) Validate() error {
+	if r.Version < 1 {
+		return errors.NewInvalidArgument("header 'If-Match' must hold the version of the transaction")
+	}
+
+	p := r.Patch
+	if p.Tags == nil && p.Note == nil && p.Category == nil {
+		return errors.NewInvalidArgument("at least one of 'tags', 'note' and 'category' must be set")
+	}
+	if p.Tags != nil {
+		if len(*p.Tags) > maxTags {
+			return errors.NewInvalidArgument(fmt.Sprintf("field 'tags' can not hold more than %d tags", maxTags))
+		}
+		for _, tag := range *p.Tags {
+			if tag == "" || len(tag) > maxTagLength {
+				return errors.NewInvalidArgument(fmt.Sprintf("field 'tags': a tag can not be empty nor longer than %d characters", maxTagLength))
+			}
+		}
+	}
+	if p.Note != nil && len(*p.Note) > maxNoteLength {
+		return errors.NewInvalidArgument(fmt.Sprintf("field 'note' can not be longer than %d characters", maxNoteLength))
+	}
+	if p.Category != nil && len(*p.Category) > maxCategoryLength {
+		return errors.NewInvalidArgument(fmt.Sprintf("field 'category' can not be longer than %d characters", maxCategoryLength))
+	}
+	return nil
+}
+
+// NormalizeTags trims the tags and removes the duplicates, keeping their order
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	result := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	return result
+}
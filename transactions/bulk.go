@@ -0,0 +1,109 @@
+package transactions
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// maxBulkLineLength bounds the size of a line of a bulk request, longer lines are reported as failed
+const maxBulkLineLength = 1 << 20
+
+// Results of a bulk item
+const (
+	BulkCreated = "created"
+	BulkUpdated = "updated"
+	BulkFailed  = "failed"
+)
+
+// BulkTransaction is a line of a bulk request. Unlike NewTransaction, it tells the user and may set the ID, in
+// which case the transaction is updated when it already exists so that a backfill can be replayed.
+type BulkTransaction struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	NewTransaction
+}
+
+// Validate checks the user and the payload
+func (b BulkTransaction) Validate() error {
+	if b.UserID == "" {
+		return fmt.Errorf("field 'user_id' is mandatory")
+	}
+	if len(b.ID) > 512 {
+		return fmt.Errorf("field 'id' is longer than 512 bytes")
+	}
+	return b.NewTransaction.Validate()
+}
+
+// ToTransaction validates the payload and builds the transaction to store
+func (b BulkTransaction) ToTransaction(now time.Time) (*Transaction, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	return b.NewTransaction.toTransaction(b.ID, b.UserID, now), nil
+}
+
+// BulkItemResult is the outcome of a line of a bulk request, lines being numbered from 1
+type BulkItemResult struct {
+	Line   int    `json:"line"`
+	ID     string `json:"id,omitempty"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BulkReport is the outcome of a bulk request, with an item per non empty line
+type BulkReport struct {
+	Total     int               `json:"total"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Items     []*BulkItemResult `json:"items"`
+}
+
+func (r *BulkReport) add(item *BulkItemResult) {
+	r.Total++
+	if item.Result == BulkFailed {
+		r.Failed++
+	} else {
+		r.Succeeded++
+	}
+	r.Items = append(r.Items, item)
+}
+
+// decodeBulkLine parses and validates a line, the error being the reason to report
+func decodeBulkLine(line []byte, now time.Time) (*Transaction, error) {
+	var b BulkTransaction
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&b); err != nil {
+		return nil, fmt.Errorf("could not decode the transaction: %s", err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("a line must hold a single transaction")
+	}
+	return b.ToTransaction(now)
+}
+
+// readBulkLine reads the next line, without its end of line. A line longer than maxBulkLineLength is skipped
+// and reported as too long.
+func readBulkLine(r *bufio.Reader) (line []byte, tooLong bool, err error) {
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxBulkLineLength {
+			tooLong = true
+			line = nil
+		} else if !tooLong {
+			line = append(line, chunk...)
+		}
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && (len(line) > 0 || tooLong) {
+			err = nil
+		}
+		return bytes.TrimSpace(line), tooLong, err
+	}
+}
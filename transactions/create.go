@@ -0,0 +1,128 @@
+package transactions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+)
+
+// ErrTransactionExists is returned by Repository.Create when a transaction with the same ID is already stored
+var ErrTransactionExists = stderrors.New("transaction already exists")
+
+// maxIdempotencyKeyLength bounds the size of the Idempotency-Key header
+const maxIdempotencyKeyLength = 255
+
+// knownTypes are the types a transaction can be created with, and thus the only ones a query can filter on
+var knownTypes = map[string]bool{
+	TypeFee:     true,
+	TypeCredit:  true,
+	TypeRefund:  true,
+	TypePayment: true,
+	TypeInvoice: true,
+	TypeReceipt: true,
+}
+
+// NewTransaction is the payload of a transaction creation, the ID and the user are set by the service
+type NewTransaction struct {
+	Type            string          `json:"type"`
+	Status          Status          `json:"status"`
+	Amount          float64         `json:"amount"`
+	Currency        string          `json:"currency"`
+	Counterparty    *Counterparty   `json:"counterparty"`
+	Description     string          `json:"description"`
+	Reference       string          `json:"reference"`
+	CreationDate    *time.Time      `json:"creation_date"`
+	DueDate         *time.Time      `json:"due_date"`
+	LinkedDocuments []*DocumentLink `json:"linked_documents"`
+}
+
+// Validate checks the payload against the Transaction model. Amounts are positive, the type tells the direction.
+func (n NewTransaction) Validate() error {
+	if !knownTypes[n.Type] {
+		return errors.NewInvalidArgument("field 'type' does not match any of the accepted values")
+	}
+	if n.Status != "" && !n.Status.IsValid() {
+		return errors.NewInvalidArgument("field 'status' does not match any of the accepted values")
+	}
+	if n.Amount < 0 {
+		return errors.NewInvalidArgument("field 'amount' cannot be negative")
+	}
+	if !isCurrencyValid(n.Currency) {
+		return errors.NewInvalidArgument("field 'currency' must be an ISO 4217 code")
+	}
+	if n.CreationDate != nil && n.DueDate != nil && n.DueDate.Before(*n.CreationDate) {
+		return errors.NewInvalidArgument("field 'due_date' must be after 'creation_date'")
+	}
+	for _, link := range n.LinkedDocuments {
+		if link == nil || link.Type == "" || link.ID == "" {
+			return errors.NewInvalidArgument("field 'linked_documents' must only hold a type and an ID")
+		}
+	}
+	return nil
+}
+
+// CreateRequest asks for the creation of a transaction. Retrying it with the same IdempotencyKey never creates
+// a second transaction.
+type CreateRequest struct {
+	UserID         string         `json:"user_id"`
+	IdempotencyKey string         `json:"idempotency_key"`
+	Transaction    NewTransaction `json:"transaction"`
+}
+
+// Validate checks the idempotency key and the payload
+func (r CreateRequest) Validate() error {
+	if r.IdempotencyKey == "" || len(r.IdempotencyKey) > maxIdempotencyKeyLength {
+		return errors.NewInvalidArgument(fmt.Sprintf("header 'Idempotency-Key' is mandatory and at most %d characters long", maxIdempotencyKeyLength))
+	}
+	return r.Transaction.Validate()
+}
+
+// transactionID derives the ID of the created transaction from the user and the idempotency key, so that a
+// retried request targets the same document
+func (r CreateRequest) transactionID() string {
+	sum := sha256.Sum256([]byte(r.UserID + "\x00" + r.IdempotencyKey))
+	return hex.EncodeToString(sum[:20])
+}
+
+// fingerprint identifies the payload, to tell a retry from a reuse of the key for another transaction
+func (r CreateRequest) fingerprint() string {
+	b, _ := json.Marshal(r.Transaction)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// toTransaction builds the transaction to store, a transaction is created open and now unless told otherwise
+func (n NewTransaction) toTransaction(id, userID string, now time.Time) *Transaction {
+	transaction := &Transaction{
+		ID:              id,
+		UserID:          userID,
+		Type:            n.Type,
+		Status:          n.Status,
+		Amount:          n.Amount,
+		Currency:        n.Currency,
+		Counterparty:    n.Counterparty,
+		Description:     n.Description,
+		Reference:       n.Reference,
+		CreationDate:    now,
+		DueDate:         n.DueDate,
+		LinkedDocuments: n.LinkedDocuments,
+	}
+	if transaction.Status == "" {
+		transaction.Status = StatusOpen
+	}
+	if n.CreationDate != nil {
+		transaction.CreationDate = *n.CreationDate
+	}
+	return transaction
+}
+
+func (r CreateRequest) toTransaction(now time.Time) *Transaction {
+	transaction := r.Transaction.toTransaction(r.transactionID(), r.UserID, now)
+	transaction.IdempotencyFingerprint = r.fingerprint()
+	return transaction
+}
@@ -0,0 +1,45 @@
+package transactions
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+)
+
+// Cursor marks the position of the last transaction of a page. It holds the sort values of that transaction
+// so the repository can resume right after it, whatever was inserted in the meantime.
+type Cursor struct {
+	// Sort is the sort the cursor was built for, a cursor can not be reused with another sort
+	Sort string `json:"s"`
+	// Values are the sort values of the last transaction, as returned by the repository
+	Values []interface{} `json:"v"`
+}
+
+// String encodes the cursor into the opaque token handed out to clients
+func (c *Cursor) String() string {
+	if c == nil {
+		return ""
+	}
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ParseCursor decodes a token produced by Cursor.String
+func ParseCursor(token string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errors.NewInvalidArgument("invalid parameter 'cursor'")
+	}
+
+	// keep numbers untouched, sort values can be large epoch millis
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+
+	var cursor Cursor
+	if err := decoder.Decode(&cursor); err != nil || len(cursor.Values) == 0 {
+		return nil, errors.NewInvalidArgument("invalid parameter 'cursor'")
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,170 @@
+package transactions
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+)
+
+// dateMathOperation matches the next operation of a relative date expression, see ParseDate. A space is a plus
+// sign decoded from a query string.
+var dateMathOperation = regexp.MustCompile(`^(?:([+ -])(\d+)|/)([yMwdhms])`)
+
+// ParseDate resolves an RFC3339 timestamp or a relative date expression, `now` being expressed in the time zone
+// of the expected result.
+//
+// Relative expressions are similar to the ElasticSearch date math: `now`, followed by any number of `+<n><unit>`,
+// `-<n><unit>` or `/<unit>` (round down), the units being y (year), M (month), w (week), d (day), h (hour),
+// m (minute) and s (second). For example `now-30d`, `now/M` (start of the month) or `now-1M/M` (start of the
+// previous month).
+//
+// In a query string, the plus sign must be escaped as %2B, an unescaped one decoding to a space: `now 1d` is
+// thus read as `now+1d`.
+//
+// Calendar units are computed in the requested time zone, so `now/d` is the local midnight and `now-1d`
+// keeps the wall clock across DST changes. Upper bounds are exclusive, so `date_from=now-1M/M&date_to=now/M`
+// is the whole last month.
+func ParseDate(value string, now time.Time) (time.Time, error) {
+	if !strings.HasPrefix(value, "now") {
+		return time.Parse(time.RFC3339, value)
+	}
+
+	date := now
+	for rest := value[len("now"):]; rest != ""; {
+		operation := dateMathOperation.FindStringSubmatch(rest)
+		if operation == nil {
+			return time.Time{}, fmt.Errorf("invalid date math '%s'", rest)
+		}
+		rest = rest[len(operation[0]):]
+
+		unit := operation[3]
+		if operation[1] == "" {
+			date = roundDown(date, unit)
+			continue
+		}
+
+		n, err := strconv.Atoi(operation[2])
+		if err != nil {
+			return time.Time{}, err
+		}
+		if operation[1] == "-" {
+			n = -n
+		}
+		date = addUnits(date, n, unit)
+	}
+	return date, nil
+}
+
+func addUnits(date time.Time, n int, unit string) time.Time {
+	switch unit {
+	case "y":
+		return addMonths(date, 12*n)
+	case "M":
+		return addMonths(date, n)
+	case "w":
+		return date.AddDate(0, 0, 7*n)
+	case "d":
+		return date.AddDate(0, 0, n)
+	case "h":
+		return date.Add(time.Duration(n) * time.Hour)
+	case "m":
+		return date.Add(time.Duration(n) * time.Minute)
+	}
+	return date.Add(time.Duration(n) * time.Second)
+}
+
+// addMonths moves the date by n months, clamping the day to the end of the month like ElasticSearch does:
+// one month before march 30th is february 28th, not march 2nd
+func addMonths(date time.Time, n int) time.Time {
+	y, M, d := date.Date()
+	h, m, s := date.Clock()
+
+	lastDay := time.Date(y, M+time.Month(n)+1, 0, 0, 0, 0, 0, date.Location()).Day()
+	if d > lastDay {
+		d = lastDay
+	}
+	return time.Date(y, M+time.Month(n), d, h, m, s, date.Nanosecond(), date.Location())
+}
+
+// roundDown truncates a date to the start of the unit, weeks starting on monday
+func roundDown(date time.Time, unit string) time.Time {
+	y, M, d := date.Date()
+	h, m, s := date.Clock()
+	loc := date.Location()
+
+	switch unit {
+	case "y":
+		return time.Date(y, time.January, 1, 0, 0, 0, 0, loc)
+	case "M":
+		return time.Date(y, M, 1, 0, 0, 0, 0, loc)
+	case "w":
+		return time.Date(y, M, d-(int(date.Weekday())+6)%7, 0, 0, 0, 0, loc)
+	case "d":
+		return time.Date(y, M, d, 0, 0, 0, 0, loc)
+	case "h":
+		return time.Date(y, M, d, h, 0, 0, 0, loc)
+	case "m":
+		return time.Date(y, M, d, h, m, 0, 0, loc)
+	}
+	return time.Date(y, M, d, h, m, s, 0, loc)
+}
+
+var quarterPeriod = regexp.MustCompile(`^q([1-4])-(\d{4})$`)
+
+// ResolvePeriod turns a named period into its [from, to) bounds. Accepted periods are today, yesterday,
+// this_week, last_week, this_month, last_month, this_quarter, last_quarter, this_year, last_year,
+// ytd (from the start of the year to now), q<n>-<year> (like q3-2026) and <year> (like 2026).
+func ResolvePeriod(period string, now time.Time) (from, to time.Time, err error) {
+	if q := quarterPeriod.FindStringSubmatch(period); q != nil {
+		quarter, _ := strconv.Atoi(q[1])
+		year, _ := strconv.Atoi(q[2])
+		from = time.Date(year, time.Month(3*(quarter-1)+1), 1, 0, 0, 0, 0, now.Location())
+		return from, from.AddDate(0, 3, 0), nil
+	}
+
+	if year, convErr := strconv.Atoi(period); convErr == nil && len(period) == 4 {
+		from = time.Date(year, time.January, 1, 0, 0, 0, 0, now.Location())
+		return from, from.AddDate(1, 0, 0), nil
+	}
+
+	startOfQuarter := time.Date(now.Year(), time.Month(3*((int(now.Month())-1)/3)+1), 1, 0, 0, 0, 0, now.Location())
+
+	switch period {
+	case "today":
+		from = roundDown(now, "d")
+		return from, from.AddDate(0, 0, 1), nil
+	case "yesterday":
+		to = roundDown(now, "d")
+		return to.AddDate(0, 0, -1), to, nil
+	case "this_week":
+		from = roundDown(now, "w")
+		return from, from.AddDate(0, 0, 7), nil
+	case "last_week":
+		to = roundDown(now, "w")
+		return to.AddDate(0, 0, -7), to, nil
+	case "this_month":
+		from = roundDown(now, "M")
+		return from, from.AddDate(0, 1, 0), nil
+	case "last_month":
+		to = roundDown(now, "M")
+		return to.AddDate(0, -1, 0), to, nil
+	case "this_quarter":
+		return startOfQuarter, startOfQuarter.AddDate(0, 3, 0), nil
+	case "last_quarter":
+		return startOfQuarter.AddDate(0, -3, 0), startOfQuarter, nil
+	case "this_year":
+		from = roundDown(now, "y")
+		return from, from.AddDate(1, 0, 0), nil
+	case "last_year":
+		to = roundDown(now, "y")
+		return to.AddDate(-1, 0, 0), to, nil
+	case "ytd":
+		return roundDown(now, "y"), now, nil
+	}
+
+	return from, to, errors.NewInvalidArgument(fmt.Sprintf("invalid parameter 'period': unknown period '%s'", period))
+}
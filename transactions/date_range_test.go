@@ -0,0 +1,171 @@
+package transactions
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	// a tuesday, the last day of a month of 31 days
+	now := time.Date(2026, 3, 31, 14, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2026-01-02T03:04:05Z", time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"now", now},
+		{"now-30d", time.Date(2026, 3, 1, 14, 30, 15, 0, time.UTC)},
+		{"now+1d", time.Date(2026, 4, 1, 14, 30, 15, 0, time.UTC)},
+		// the plus sign of an unescaped query string
+		{"now 1d", time.Date(2026, 4, 1, 14, 30, 15, 0, time.UTC)},
+		{"now-2w", time.Date(2026, 3, 17, 14, 30, 15, 0, time.UTC)},
+		{"now-1M", time.Date(2026, 2, 28, 14, 30, 15, 0, time.UTC)},
+		{"now-13M", time.Date(2025, 2, 28, 14, 30, 15, 0, time.UTC)},
+		{"now+1y", time.Date(2027, 3, 31, 14, 30, 15, 0, time.UTC)},
+		{"now-90m", time.Date(2026, 3, 31, 13, 0, 15, 0, time.UTC)},
+		{"now-15s", time.Date(2026, 3, 31, 14, 30, 0, 0, time.UTC)},
+		{"now/d", time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)},
+		{"now/w", time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC)},
+		{"now/M", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"now-1M/M", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"now/y", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"now+2h/h", time.Date(2026, 3, 31, 16, 0, 0, 0, time.UTC)},
+		{"now/d-1d+12h", time.Date(2026, 3, 30, 12, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDate(tt.value, now)
+		if err != nil {
+			t.Errorf("%s: %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%s = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+
+	for _, value := range []string{"", "yesterday", "now-", "now+1", "now-1x", "now++1d", "now/", "2026-01-02"} {
+		if got, err := ParseDate(value, now); err == nil {
+			t.Errorf("%q: expected an error, got %s", value, got)
+		}
+	}
+}
+
+func TestParseDateAcrossDST(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("the time zone database is missing: %v", err)
+	}
+	// the clocks went forward at 2am, the day was 23 hours long
+	now := time.Date(2026, 3, 29, 12, 0, 0, 0, paris)
+
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"now-1d", time.Date(2026, 3, 28, 12, 0, 0, 0, paris)},
+		{"now-24h", time.Date(2026, 3, 28, 11, 0, 0, 0, paris)},
+		{"now/d", time.Date(2026, 3, 29, 0, 0, 0, 0, paris)},
+		{"now/d+1d", time.Date(2026, 3, 30, 0, 0, 0, 0, paris)},
+		{"now/w", time.Date(2026, 3, 23, 0, 0, 0, 0, paris)},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDate(tt.value, now)
+		if err != nil {
+			t.Errorf("%s: %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%s = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+
+	from, _ := ParseDate("now/d", now)
+	to, _ := ParseDate("now/d+1d", now)
+	if to.Sub(from) != 23*time.Hour {
+		t.Errorf("expected the day of the DST change to last 23 hours, got %s", to.Sub(from))
+	}
+}
+
+func TestResolvePeriod(t *testing.T) {
+	// a sunday
+	now := time.Date(2026, 10, 18, 15, 4, 5, 0, time.UTC)
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		period   string
+		from, to time.Time
+	}{
+		{"today", day(2026, 10, 18), day(2026, 10, 19)},
+		{"yesterday", day(2026, 10, 17), day(2026, 10, 18)},
+		{"this_week", day(2026, 10, 12), day(2026, 10, 19)},
+		{"last_week", day(2026, 10, 5), day(2026, 10, 12)},
+		{"this_month", day(2026, 10, 1), day(2026, 11, 1)},
+		{"last_month", day(2026, 9, 1), day(2026, 10, 1)},
+		{"this_quarter", day(2026, 10, 1), day(2027, 1, 1)},
+		{"last_quarter", day(2026, 7, 1), day(2026, 10, 1)},
+		{"this_year", day(2026, 1, 1), day(2027, 1, 1)},
+		{"last_year", day(2025, 1, 1), day(2026, 1, 1)},
+		{"ytd", day(2026, 1, 1), now},
+		{"q1-2025", day(2025, 1, 1), day(2025, 4, 1)},
+		{"q4-2026", day(2026, 10, 1), day(2027, 1, 1)},
+		{"2024", day(2024, 1, 1), day(2025, 1, 1)},
+	}
+
+	for _, tt := range tests {
+		from, to, err := ResolvePeriod(tt.period, now)
+		if err != nil {
+			t.Errorf("%s: %v", tt.period, err)
+			continue
+		}
+		if !from.Equal(tt.from) || !to.Equal(tt.to) {
+			t.Errorf("%s = [%s, %s), want [%s, %s)", tt.period, from, to, tt.from, tt.to)
+		}
+	}
+
+	for _, period := range []string{"", "fortnight", "q5-2026", "q1-26", "20260"} {
+		if _, _, err := ResolvePeriod(period, now); statusCode(err) != http.StatusBadRequest {
+			t.Errorf("%q: expected an invalid argument, got %v", period, err)
+		}
+	}
+}
+
+func TestResolvePeriodAcrossDST(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("the time zone database is missing: %v", err)
+	}
+	// the clocks went back on sunday october 25th, the last week was 169 hours long
+	now := time.Date(2026, 10, 27, 10, 0, 0, 0, paris)
+
+	from, to, err := ResolvePeriod("last_week", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !from.Equal(time.Date(2026, 10, 19, 0, 0, 0, 0, paris)) || !to.Equal(time.Date(2026, 10, 26, 0, 0, 0, 0, paris)) {
+		t.Errorf("expected the local midnights of the mondays, got [%s, %s)", from, to)
+	}
+	if to.Sub(from) != 169*time.Hour {
+		t.Errorf("expected the week to last 169 hours, got %s", to.Sub(from))
+	}
+}
+
+func TestDecodeFiltersReadsAnUnescapedPlus(t *testing.T) {
+	params, err := url.ParseQuery("date_from=now+1d/d&date_to=now%2B1d/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := NewTransactionQuery()
+	if err := DecodeFilters(params, &query); err != nil {
+		t.Fatal(err)
+	}
+	if query.DateFrom == nil || query.DateTo == nil || !query.DateFrom.Equal(*query.DateTo) {
+		t.Errorf("expected the unescaped and the escaped plus to be read alike, got %v and %v", query.DateFrom, query.DateTo)
+	}
+}
@@ -2,30 +2,47 @@ package transactions
 
 import (
 	"context"
+	"io"
 
 	"github.com/go-kit/kit/endpoint"
 )
 
 // Endpoints represents all endpoints
 type Endpoints struct {
-	GetByUserEndpoint endpoint.Endpoint
-	GetEndpoint       endpoint.Endpoint
+	GetByUserEndpoint        endpoint.Endpoint
+	GetEndpoint              endpoint.Endpoint
+	GetSummaryByUserEndpoint endpoint.Endpoint
+	GetByIDEndpoint          endpoint.Endpoint
+	GetRelatedEndpoint       endpoint.Endpoint
+	ExportEndpoint           endpoint.Endpoint
+	CreateEndpoint           endpoint.Endpoint
+	BulkEndpoint             endpoint.Endpoint
+	WatchEndpoint            endpoint.Endpoint
+	UpdateEndpoint           endpoint.Endpoint
 }
 
 func MakeEndpoints(s Service) Endpoints {
 	return Endpoints{
-		GetByUserEndpoint: makeGetByUserEndpoint(s),
-		GetEndpoint:       makeGetEndpoint(s),
+		GetByUserEndpoint:        makeGetByUserEndpoint(s),
+		GetEndpoint:              makeGetEndpoint(s),
+		GetSummaryByUserEndpoint: makeGetSummaryByUserEndpoint(s),
+		GetByIDEndpoint:          makeGetByIDEndpoint(s),
+		GetRelatedEndpoint:       makeGetRelatedEndpoint(s),
+		ExportEndpoint:           makeExportEndpoint(s),
+		CreateEndpoint:           makeCreateEndpoint(s),
+		BulkEndpoint:             makeBulkEndpoint(s),
+		WatchEndpoint:            makeWatchEndpoint(s),
+		UpdateEndpoint:           makeUpdateEndpoint(s),
 	}
 }
 
 func makeGetByUserEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		req := request.(TransactionsRequest)
-		transactions, total, err := s.GetByUser(ctx, *req.UserID, req.Type, req.Sort, req.Page, req.PageSize, req.DateFrom, req.DateTo, req.Open)
+		query := request.(TransactionQuery)
+		page, err := s.GetByUser(ctx, query)
 
 		if nil == err {
-			return TransactionsResponse{Transactions: transactions, Total: total}, nil
+			return TransactionsResponse{Transactions: page.Transactions, Total: page.Total, NextCursor: page.NextCursor.String()}, nil
 		}
 
 		return TransactionsResponse{}, err
@@ -34,8 +51,8 @@ func makeGetByUserEndpoint(s Service) endpoint.Endpoint {
 
 func makeGetEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		req := request.(TransactionsRequest)
-		transactions, total, err := s.GetByDateRange(ctx, req.Type, req.DateFrom, req.DateTo)
+		query := request.(TransactionQuery)
+		transactions, total, err := s.GetByDateRange(ctx, query)
 
 		if nil == err {
 			return TransactionsResponse{Transactions: transactions, Total: total}, nil
@@ -44,3 +61,96 @@ func makeGetEndpoint(s Service) endpoint.Endpoint {
 		return TransactionsResponse{}, err
 	}
 }
+
+func makeGetSummaryByUserEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SummaryRequest)
+		return s.GetSummaryByUser(ctx, req)
+	}
+}
+
+func makeGetByIDEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(TransactionRequest)
+		return s.GetByID(ctx, req)
+	}
+}
+
+func makeGetRelatedEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(TransactionRequest)
+		return s.GetRelated(ctx, req)
+	}
+}
+
+// exportResponse defers the export to the response encoder, which streams it to the client
+type exportResponse struct {
+	request   ExportRequest
+	statement *Statement
+	export    func(fn func([]*Transaction) error) error
+}
+
+func makeExportEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ExportRequest)
+
+		// balances are computed before anything is streamed, so that a failure can still be reported properly
+		var statement *Statement
+		if req.Format.IsStatement() {
+			var err error
+			if statement, err = s.GetStatement(ctx, req.Query); err != nil {
+				return nil, err
+			}
+		}
+
+		return exportResponse{
+			request:   req,
+			statement: statement,
+			export: func(fn func([]*Transaction) error) error {
+				return s.Export(ctx, req, fn)
+			},
+		}, nil
+	}
+}
+
+func makeCreateEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(CreateRequest)
+		return s.Create(ctx, req)
+	}
+}
+
+// bulkRequest holds the NDJSON body, which is read by the service as it goes
+type bulkRequest struct {
+	body io.Reader
+}
+
+func makeBulkEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(bulkRequest)
+		return s.Bulk(ctx, req.body)
+	}
+}
+
+// watchResponse defers the watch to the encoder, which streams the changes as they are found
+type watchResponse struct {
+	watch func(ctx context.Context, fn func([]*Change) error) error
+}
+
+func makeWatchEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(WatchRequest)
+		return watchResponse{
+			watch: func(ctx context.Context, fn func([]*Change) error) error {
+				return s.Watch(ctx, req, fn)
+			},
+		}, nil
+	}
+}
+
+func makeUpdateEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UpdateRequest)
+		return s.Update(ctx, req)
+	}
+}
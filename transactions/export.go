@@ -0,0 +1,181 @@
+package transactions
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+)
+
+// ExportFormat is the file format of an export
+type ExportFormat string
+
+// All the export formats
+const (
+	ExportCSV    ExportFormat = "csv"
+	ExportNDJSON ExportFormat = "ndjson"
+	ExportOFX    ExportFormat = "ofx"
+	ExportQIF    ExportFormat = "qif"
+)
+
+// ContentType returns the MIME type of the format
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportNDJSON:
+		return "application/x-ndjson; charset=utf-8"
+	case ExportOFX:
+		return "application/x-ofx; charset=utf-8"
+	case ExportQIF:
+		return "application/qif; charset=utf-8"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// IsStatement tells whether the format is a bank statement, which reports the balances of the period
+func (f ExportFormat) IsStatement() bool {
+	return f == ExportOFX || f == ExportQIF
+}
+
+// exportColumns are the columns a CSV export can be made of, with the way to render them
+var exportColumns = map[string]func(t *Transaction) string{
+	"id":       func(t *Transaction) string { return t.ID },
+	"user_id":  func(t *Transaction) string { return t.UserID },
+	"type":     func(t *Transaction) string { return t.Type },
+	"status":   func(t *Transaction) string { return string(t.Status) },
+	"amount":   func(t *Transaction) string { return strconv.FormatFloat(t.Amount, 'f', -1, 64) },
+	"currency": func(t *Transaction) string { return t.Currency },
+	"counterparty": func(t *Transaction) string {
+		if t.Counterparty == nil {
+			return ""
+		}
+		return t.Counterparty.Name
+	},
+	"description":   func(t *Transaction) string { return t.Description },
+	"reference":     func(t *Transaction) string { return t.Reference },
+	"creation_date": func(t *Transaction) string { return t.CreationDate.Format(time.RFC3339) },
+	"due_date": func(t *Transaction) string {
+		if t.DueDate == nil {
+			return ""
+		}
+		return t.DueDate.Format(time.RFC3339)
+	},
+}
+
+// DefaultExportColumns are the CSV columns exported when the client does not pick them
+var DefaultExportColumns = []string{"id", "creation_date", "type", "status", "amount", "currency", "counterparty", "description", "reference"}
+
+// ExportRequest asks for all the transactions matching Query, written in Format
+type ExportRequest struct {
+	Query   TransactionQuery `json:"query"`
+	Format  ExportFormat     `json:"format"`
+	Columns []string         `json:"columns"`
+}
+
+// Validate checks the format, the columns and the query. Sort and pagination of the query are ignored.
+func (r ExportRequest) Validate() error {
+	switch r.Format {
+	case ExportCSV, ExportNDJSON:
+	case ExportOFX, ExportQIF:
+		if err := validateStatement(r.Query); err != nil {
+			return err
+		}
+	default:
+		return errors.NewInvalidArgument("invalid parameter 'format'")
+	}
+
+	for _, column := range r.Columns {
+		if _, ok := exportColumns[column]; !ok {
+			return errors.NewInvalidArgument(fmt.Sprintf("invalid parameter 'columns': unknown column '%s'", column))
+		}
+	}
+
+	return r.Query.Validate()
+}
+
+// ExportWriter writes transactions one after the other in an export format
+type ExportWriter interface {
+	// Write adds the transactions to the export
+	Write(transactions []*Transaction) error
+	// Close terminates the export, it must be called even if nothing was written
+	Close() error
+}
+
+// NewExportWriter returns a writer for the format of the request, statement formats need the statement
+func NewExportWriter(w io.Writer, request ExportRequest, statement *Statement) ExportWriter {
+	switch request.Format {
+	case ExportNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}
+	case ExportOFX:
+		return newOFXWriter(w, statement)
+	case ExportQIF:
+		return newQIFWriter(w, statement)
+	}
+
+	columns := request.Columns
+	if len(columns) == 0 {
+		columns = DefaultExportColumns
+	}
+	return &csvWriter{writer: csv.NewWriter(w), columns: columns}
+}
+
+type csvWriter struct {
+	writer        *csv.Writer
+	columns       []string
+	headerWritten bool
+}
+
+func (c *csvWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+	c.headerWritten = true
+	return c.writer.Write(c.columns)
+}
+
+func (c *csvWriter) Write(transactions []*Transaction) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	record := make([]string, len(c.columns))
+	for _, transaction := range transactions {
+		for i, column := range c.columns {
+			record[i] = exportColumns[column](transaction)
+		}
+		if err := c.writer.Write(record); err != nil {
+			return err
+		}
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(transactions []*Transaction) error {
+	for _, transaction := range transactions {
+		// Encode terminates every document with a new line
+		if err := n.encoder.Encode(transaction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,373 @@
+package transactions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+)
+
+// The `filter` parameter accepts boolean expressions over transaction fields:
+//
+//	expression := term { "OR" term }
+//	term       := factor { "AND" factor }
+//	factor     := "NOT" factor | "(" expression ")" | comparison
+//	comparison := field operator value
+//	operator   := "=" | "!=" | ">" | ">=" | "<" | "<=" | "~"
+//	value      := number | RFC3339 date | word | "double quoted string"
+//
+// Keywords are case insensitive, NOT binds tighter than AND which binds tighter than OR. `=` compares the whole
+// value, with its case. `~` means contains the words of the value in sequence, ignoring the case and the
+// punctuation, and applies to text fields only. For example:
+//
+//	(type=fee OR type=refund) AND amount>100 AND NOT status=cancelled
+//	counterparty.name~"acme" AND amount>=50
+
+// FilterOperator is a comparison operator of a filter expression
+type FilterOperator string
+
+// All the comparison operators of a filter expression
+const (
+	FilterEqual          FilterOperator = "="
+	FilterNotEqual       FilterOperator = "!="
+	FilterGreater        FilterOperator = ">"
+	FilterGreaterOrEqual FilterOperator = ">="
+	FilterLower          FilterOperator = "<"
+	FilterLowerOrEqual   FilterOperator = "<="
+	FilterContains       FilterOperator = "~"
+)
+
+type filterFieldKind int
+
+const (
+	filterString filterFieldKind = iota
+	// filterText is a string field which can also be searched with `~`
+	filterText
+	// filterSearch is a string field which can only be searched with `~`
+	filterSearch
+	filterStatus
+	filterNumber
+	filterDate
+)
+
+// filterFields is the whitelist of the fields usable in a filter expression, with the type of their values
+var filterFields = map[string]filterFieldKind{
+	"type":              filterString,
+	"status":            filterStatus,
+	"currency":          filterString,
+	"tags":              filterString,
+	"counterparty.name": filterText,
+	"reference":         filterText,
+	"description":       filterSearch,
+	"amount":            filterNumber,
+	"creation_date":     filterDate,
+	"due_date":          filterDate,
+}
+
+// FilterExpr is a node of the AST of a filter expression: FilterAnd, FilterOr, FilterNot, FilterComparison,
+// FilterExists or FilterNone
+type FilterExpr interface {
+	filterExpr()
+}
+
+// FilterAnd matches when all its operands match
+type FilterAnd struct {
+	Operands []FilterExpr
+}
+
+// FilterOr matches when at least one of its operands matches
+type FilterOr struct {
+	Operands []FilterExpr
+}
+
+// FilterNot matches when its operand does not match
+type FilterNot struct {
+	Operand FilterExpr
+}
+
+// FilterComparison compares a field to a value. Value is a string, a Status, a float64 or a time.Time
+// depending on the field.
+type FilterComparison struct {
+	Field    string
+	Operator FilterOperator
+	Value    interface{}
+}
+
+// FilterExists matches when the field has a value. It is not part of the grammar, it is built by the packages
+// resolving the query, like the stored category of the transactions.
+type FilterExists struct {
+	Field string
+}
+
+// FilterNone matches no transaction. Like FilterExists, it is only built by the packages resolving the query.
+type FilterNone struct{}
+
+func (FilterAnd) filterExpr()        {}
+func (FilterOr) filterExpr()         {}
+func (FilterNot) filterExpr()        {}
+func (FilterComparison) filterExpr() {}
+func (FilterExists) filterExpr()     {}
+func (FilterNone) filterExpr()       {}
+
+// ParseFilter parses and validates a filter expression. Errors are InvalidArgument errors telling the
+// position (starting at 1) where the expression went wrong.
+func ParseFilter(input string) (FilterExpr, error) {
+	return ParseFilterExpr("filter", input)
+}
+
+// ParseFilterExpr parses a filter expression given as the named parameter, which errors refer to
+func ParseFilterExpr(param, input string) (FilterExpr, error) {
+	tokens, err := tokenizeFilter(param, input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{tokens: tokens, param: param}
+	expr, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, p.errorf(p.peek(), "unexpected '%s'", p.peek().text)
+	}
+	return expr, nil
+}
+
+func filterError(param string, pos int, format string, args ...interface{}) error {
+	return errors.NewInvalidArgument(fmt.Sprintf("invalid parameter '%s' at position %d: %s", param, pos, fmt.Sprintf(format, args...)))
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenOperator
+	tokenOpenParen
+	tokenCloseParen
+)
+
+type filterToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.-+:", r)
+}
+
+func tokenizeFilter(param, input string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, filterToken{kind: tokenOpenParen, text: "(", pos: pos})
+			i++
+		case r == ')':
+			tokens = append(tokens, filterToken{kind: tokenCloseParen, text: ")", pos: pos})
+			i++
+		case r == '=' || r == '!' || r == '<' || r == '>':
+			op := string(r)
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, filterError(param, pos, "expected '!='")
+			}
+			tokens = append(tokens, filterToken{kind: tokenOperator, text: op, pos: pos})
+			i += len(op)
+		case r == '~':
+			tokens = append(tokens, filterToken{kind: tokenOperator, text: "~", pos: pos})
+			i++
+		case r == '"':
+			var sb strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				sb.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, filterError(param, pos, "unterminated string")
+			}
+			tokens = append(tokens, filterToken{kind: tokenString, text: sb.String(), pos: pos})
+			i++
+		case isWordRune(r):
+			start := i
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, filterToken{kind: tokenWord, text: string(runes[start:i]), pos: pos})
+		default:
+			return nil, filterError(param, pos, "unexpected character '%c'", r)
+		}
+	}
+
+	return append(tokens, filterToken{kind: tokenEOF, text: "end of filter", pos: len(runes) + 1}), nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	index  int
+	param  string
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.index]
+}
+
+func (p *filterParser) next() filterToken {
+	token := p.tokens[p.index]
+	if token.kind != tokenEOF {
+		p.index++
+	}
+	return token
+}
+
+func (p *filterParser) isKeyword(keyword string) bool {
+	token := p.peek()
+	return token.kind == tokenWord && strings.EqualFold(token.text, keyword)
+}
+
+func (p *filterParser) errorf(token filterToken, format string, args ...interface{}) error {
+	return filterError(p.param, token.pos, format, args...)
+}
+
+func (p *filterParser) parseExpression() (FilterExpr, error) {
+	operands, err := p.parseSequence("OR", p.parseTerm)
+	if err != nil {
+		return nil, err
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return FilterOr{Operands: operands}, nil
+}
+
+func (p *filterParser) parseTerm() (FilterExpr, error) {
+	operands, err := p.parseSequence("AND", p.parseFactor)
+	if err != nil {
+		return nil, err
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return FilterAnd{Operands: operands}, nil
+}
+
+// parseSequence parses operands separated by the given keyword
+func (p *filterParser) parseSequence(keyword string, parseOperand func() (FilterExpr, error)) ([]FilterExpr, error) {
+	var operands []FilterExpr
+	for {
+		operand, err := parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+
+		if !p.isKeyword(keyword) {
+			return operands, nil
+		}
+		p.next()
+	}
+}
+
+func (p *filterParser) parseFactor() (FilterExpr, error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		operand, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return FilterNot{Operand: operand}, nil
+	}
+
+	if p.peek().kind == tokenOpenParen {
+		p.next()
+		expr, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if token := p.next(); token.kind != tokenCloseParen {
+			return nil, p.errorf(token, "expected ')' but found '%s'", token.text)
+		}
+		return expr, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (FilterExpr, error) {
+	fieldToken := p.next()
+	if fieldToken.kind != tokenWord {
+		return nil, p.errorf(fieldToken, "expected a field but found '%s'", fieldToken.text)
+	}
+	kind, ok := filterFields[fieldToken.text]
+	if !ok {
+		return nil, p.errorf(fieldToken, "unknown field '%s'", fieldToken.text)
+	}
+
+	opToken := p.next()
+	if opToken.kind != tokenOperator {
+		return nil, p.errorf(opToken, "expected an operator but found '%s'", opToken.text)
+	}
+	operator := FilterOperator(opToken.text)
+	var allowed bool
+	switch kind {
+	case filterString, filterStatus:
+		allowed = operator == FilterEqual || operator == FilterNotEqual
+	case filterText:
+		allowed = operator == FilterEqual || operator == FilterNotEqual || operator == FilterContains
+	case filterSearch:
+		allowed = operator == FilterContains
+	default:
+		allowed = operator != FilterContains
+	}
+	if !allowed {
+		return nil, p.errorf(opToken, "operator '%s' can not be used on '%s'", operator, fieldToken.text)
+	}
+
+	valueToken := p.next()
+	if valueToken.kind != tokenWord && valueToken.kind != tokenString {
+		return nil, p.errorf(valueToken, "expected a value but found '%s'", valueToken.text)
+	}
+
+	var value interface{}
+	switch kind {
+	case filterString, filterText, filterSearch:
+		value = valueToken.text
+	case filterStatus:
+		status := Status(valueToken.text)
+		if !status.IsValid() {
+			return nil, p.errorf(valueToken, "unknown status '%s'", valueToken.text)
+		}
+		value = status
+	case filterNumber:
+		f, err := strconv.ParseFloat(valueToken.text, 64)
+		if err != nil {
+			return nil, p.errorf(valueToken, "'%s' is not a number", valueToken.text)
+		}
+		value = f
+	case filterDate:
+		t, err := time.Parse(time.RFC3339, valueToken.text)
+		if err != nil {
+			return nil, p.errorf(valueToken, "'%s' is not a RFC3339 date", valueToken.text)
+		}
+		value = t
+	}
+
+	return FilterComparison{Field: fieldToken.text, Operator: operator, Value: value}, nil
+}
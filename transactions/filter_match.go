@@ -0,0 +1,165 @@
+package transactions
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MatchFilter evaluates a filter expression against a transaction, the way the repository does: a comparison
+// on a missing value, like the due date of a transaction without one, does not match, while its negation does.
+func MatchFilter(expr FilterExpr, t *Transaction) bool {
+	switch e := expr.(type) {
+	case FilterAnd:
+		for _, operand := range e.Operands {
+			if !MatchFilter(operand, t) {
+				return false
+			}
+		}
+		return true
+	case FilterOr:
+		for _, operand := range e.Operands {
+			if MatchFilter(operand, t) {
+				return true
+			}
+		}
+		return false
+	case FilterNot:
+		return !MatchFilter(e.Operand, t)
+	case FilterComparison:
+		if e.Operator == FilterNotEqual {
+			return !matchComparison(FilterComparison{Field: e.Field, Operator: FilterEqual, Value: e.Value}, t)
+		}
+		return matchComparison(e, t)
+	case FilterExists:
+		switch v := filterValue(e.Field, t).(type) {
+		case nil:
+			return false
+		case string:
+			return v != ""
+		case []string:
+			return len(v) > 0
+		}
+		return true
+	case FilterNone:
+		return false
+	}
+	return false
+}
+
+// filterValue returns the value of a field of a filter expression, nil when missing
+func filterValue(field string, t *Transaction) interface{} {
+	switch field {
+	case "type":
+		return t.Type
+	case "status":
+		return t.Status
+	case "currency":
+		return t.Currency
+	case "tags":
+		return t.Tags
+	case "category":
+		return t.Category
+	case "counterparty.name":
+		if t.Counterparty == nil {
+			return nil
+		}
+		return t.Counterparty.Name
+	case "reference":
+		return t.Reference
+	case "description":
+		return t.Description
+	case "amount":
+		return t.Amount
+	case "creation_date":
+		return t.CreationDate
+	case "due_date":
+		if t.DueDate == nil {
+			return nil
+		}
+		return *t.DueDate
+	}
+	return nil
+}
+
+func matchComparison(c FilterComparison, t *Transaction) bool {
+	value := filterValue(c.Field, t)
+	if value == nil {
+		return false
+	}
+
+	switch v := value.(type) {
+	case string:
+		expected, _ := c.Value.(string)
+		if c.Operator == FilterContains {
+			return containsWords(v, expected)
+		}
+		return v == expected
+	case []string:
+		// a list matches when one of its values does
+		expected, _ := c.Value.(string)
+		for _, item := range v {
+			if item == expected {
+				return true
+			}
+		}
+		return false
+	case Status:
+		expected, _ := c.Value.(Status)
+		return v == expected
+	case float64:
+		expected, _ := c.Value.(float64)
+		return compare(c.Operator, v-expected)
+	case time.Time:
+		expected, _ := c.Value.(time.Time)
+		return compare(c.Operator, float64(v.Sub(expected)))
+	}
+	return false
+}
+
+// compare applies the operator to the sign of the difference between the value and the expected one
+func compare(operator FilterOperator, diff float64) bool {
+	switch operator {
+	case FilterEqual:
+		return diff == 0
+	case FilterGreater:
+		return diff > 0
+	case FilterGreaterOrEqual:
+		return diff >= 0
+	case FilterLower:
+		return diff < 0
+	case FilterLowerOrEqual:
+		return diff <= 0
+	}
+	return false
+}
+
+// words splits a text into lower case words, like the analyzer of the text fields of the repository
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// containsWords tells whether the words of the phrase appear in sequence in the text, whatever their case: the
+// `~` operator matches whole words, like a phrase query of the repository, "acme co" matching "ACME Co." but not
+// "Acme Corp"
+func containsWords(text, phrase string) bool {
+	haystack, needle := words(text), words(phrase)
+	if len(needle) == 0 {
+		return false
+	}
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		matched := true
+		for j, word := range needle {
+			if haystack[i+j] != word {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
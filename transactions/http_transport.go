@@ -3,17 +3,24 @@ package transactions
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/fsilberstein/parameters-issue/errors"
+	"github.com/fsilberstein/parameters-issue/logger"
 	kithttp "github.com/go-kit/kit/transport/http"
 	"github.com/gorilla/mux"
+	"go.uber.org/zap"
 )
 
-// MakeHTTPHandler ...
-func MakeHTTPHandler(endpoints Endpoints, router *mux.Router) http.Handler {
+// MakeHTTPHandler registers the routes of the endpoints. Streams of changes end when shutdown is closed, for the
+// server to be able to shut down.
+func MakeHTTPHandler(endpoints Endpoints, router *mux.Router, shutdown <-chan struct{}) http.Handler {
 
 	options := []kithttp.ServerOption{
 		kithttp.ServerBefore(kithttp.PopulateRequestContext),
@@ -34,14 +41,86 @@ func MakeHTTPHandler(endpoints Endpoints, router *mux.Router) http.Handler {
 		options...,
 	)
 
+	getSummaryByUserHandler := kithttp.NewServer(
+		endpoints.GetSummaryByUserEndpoint,
+		decodeGetSummaryByUserRequest,
+		encodeResponse,
+		options...,
+	)
+
+	getByIDHandler := kithttp.NewServer(
+		endpoints.GetByIDEndpoint,
+		decodeGetByIDRequest,
+		encodeResponse,
+		options...,
+	)
+
+	getRelatedHandler := kithttp.NewServer(
+		endpoints.GetRelatedEndpoint,
+		decodeGetRelatedRequest,
+		encodeResponse,
+		options...,
+	)
+
+	exportByUserHandler := kithttp.NewServer(
+		endpoints.ExportEndpoint,
+		decodeExportByUserRequest,
+		encodeExportResponse,
+		options...,
+	)
+
+	exportHandler := kithttp.NewServer(
+		endpoints.ExportEndpoint,
+		decodeExportRequest,
+		encodeExportResponse,
+		options...,
+	)
+
+	createHandler := kithttp.NewServer(
+		endpoints.CreateEndpoint,
+		decodeCreateRequest,
+		encodeCreatedResponse,
+		options...,
+	)
+
+	bulkHandler := kithttp.NewServer(
+		endpoints.BulkEndpoint,
+		decodeBulkRequest,
+		encodeResponse,
+		options...,
+	)
+
+	watchHandler := kithttp.NewServer(
+		endpoints.WatchEndpoint,
+		decodeWatchRequest,
+		encodeWatchResponse(shutdown),
+		options...,
+	)
+
+	updateHandler := kithttp.NewServer(
+		endpoints.UpdateEndpoint,
+		decodeUpdateRequest,
+		encodeResponse,
+		options...,
+	)
+
 	ur := router.PathPrefix("/users").Subrouter().StrictSlash(true)
 	{
 		ur.Handle("/{id}/transactions/", getByUserHandler).Methods("GET")
+		ur.Handle("/{id}/transactions/", createHandler).Methods("POST")
+		ur.Handle("/{id}/transactions/summary", getSummaryByUserHandler).Methods("GET")
+		ur.Handle("/{id}/transactions/export", exportByUserHandler).Methods("GET")
+		ur.Handle("/{id}/transactions/stream", watchHandler).Methods("GET")
+		ur.Handle("/{id}/transactions/{transactionID}", getByIDHandler).Methods("GET")
+		ur.Handle("/{id}/transactions/{transactionID}", updateHandler).Methods("PATCH")
+		ur.Handle("/{id}/transactions/{transactionID}/related", getRelatedHandler).Methods("GET")
 	}
 
 	tr := router.PathPrefix("/transactions").Subrouter().StrictSlash(true)
 	{
 		tr.Handle("/", getHandler).Methods("GET")
+		tr.Handle("/export", exportHandler).Methods("GET")
+		tr.Handle("/_bulk", bulkHandler).Methods("POST")
 	}
 
 	return router
@@ -54,34 +133,23 @@ func decodeGetByUserRequest(ctx context.Context, r *http.Request) (interface{},
 		return nil, errors.NewInvalidArgument("invalid parameter 'user_id'")
 	}
 
-	//init request with default value and handle non-mandatory parameters
-	req := TransactionsRequest{UserID: &id, Sort: "desc"}
+	//init query with default value and handle non-mandatory parameters
+	query := NewTransactionQuery()
+	query.UserID = &id
 
 	params := r.URL.Query()
 
-	// check `type` parameter
-	if transactionType, pTypeOk := params["type"]; pTypeOk && len(transactionType) > 0 {
-		var typeArray []string
-		for _, value := range transactionType {
-			if isTypeValid(value) {
-				typeArray = append(typeArray, value)
-			} else {
-				return nil, errors.NewInvalidArgument("parameter 'type' does not match any of the accepted values")
-			}
-		}
-
-		if len(typeArray) > 0 {
-			req.Type = typeArray
-		}
+	if err := DecodeFilters(params, &query); err != nil {
+		return nil, err
 	}
 
 	sort, ok := params["sort"]
 	if ok && len(sort) > 0 {
-		// right now, only asc and desc are accepted
-		if sort[0] != "asc" && sort[0] != "desc" {
-			return nil, errors.NewInvalidArgument("invalid parameter 'sort'")
+		spec, err := ParseSort(sort[0])
+		if err != nil {
+			return nil, err
 		}
-		req.Sort = sort[0]
+		query.Sort = spec
 	}
 
 	page, ok := params["page"]
@@ -90,9 +158,7 @@ func decodeGetByUserRequest(ctx context.Context, r *http.Request) (interface{},
 		if err != nil || i < 1 {
 			return nil, errors.NewInvalidArgument("invalid parameter 'page'")
 		}
-		req.Page = int(i)
-	} else {
-		req.Page = 1
+		query.Page = int(i)
 	}
 
 	pageSize, ok := params["page_size"]
@@ -101,93 +167,497 @@ func decodeGetByUserRequest(ctx context.Context, r *http.Request) (interface{},
 		if err != nil || i < 1 {
 			return nil, errors.NewInvalidArgument("invalid parameter 'page_size'")
 		}
-		req.PageSize = int(i)
-	} else {
-		req.PageSize = -1
+		query.PageSize = int(i)
 	}
 
-	dateFromStr, ok := params["date_from"]
-	if ok {
-		dateFrom, err := time.Parse(time.RFC3339, dateFromStr[0])
+	cursor, ok := params["cursor"]
+	if ok && len(cursor) > 0 {
+		if len(page) > 0 {
+			return nil, errors.NewInvalidArgument("parameters 'cursor' and 'page' cannot be used together")
+		}
+		c, err := ParseCursor(cursor[0])
 		if err != nil {
-			return nil, errors.NewInvalidArgument("could not decode `date_from`")
+			return nil, err
 		}
-		req.DateFrom = &dateFrom
+		query.Cursor = c
 	}
 
-	dateToStr, ok := params["date_to"]
-	if ok {
-		dateTo, err := time.Parse(time.RFC3339, dateToStr[0])
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	return query, nil
+}
+
+func decodeGetRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	params := r.URL.Query()
+
+	query := NewTransactionQuery()
+
+	if err := DecodeFilters(params, &query); err != nil {
+		return nil, err
+	}
+
+	if query.DateFrom == nil && query.DateTo == nil {
+		return nil, errors.NewInvalidArgument("at least one of the date range boundaries must be set")
+	}
+
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	return query, nil
+}
+
+func decodeGetSummaryByUserRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+	if !ok {
+		return nil, errors.NewInvalidArgument("invalid parameter 'user_id'")
+	}
+
+	request := SummaryRequest{Query: NewTransactionQuery(), Interval: IntervalMonth}
+	request.Query.UserID = &id
+
+	params := r.URL.Query()
+
+	if err := DecodeFilters(params, &request.Query); err != nil {
+		return nil, err
+	}
+
+	interval, ok := params["interval"]
+	if ok && len(interval) > 0 {
+		request.Interval = Interval(interval[0])
+	}
+
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+func decodeExportByUserRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+	if !ok {
+		return nil, errors.NewInvalidArgument("invalid parameter 'user_id'")
+	}
+
+	request, err := decodeExport(r.URL.Query(), &id)
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func decodeExportRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	request, err := decodeExport(r.URL.Query(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Query.DateFrom == nil && request.Query.DateTo == nil {
+		return nil, errors.NewInvalidArgument("at least one of the date range boundaries must be set")
+	}
+	return request, nil
+}
+
+// decodeExport reads the filters and the export options shared by the export routes
+func decodeExport(params url.Values, userID *string) (ExportRequest, error) {
+	request := ExportRequest{Query: NewTransactionQuery(), Format: ExportCSV}
+	request.Query.UserID = userID
+
+	if err := DecodeFilters(params, &request.Query); err != nil {
+		return request, err
+	}
+
+	format, ok := params["format"]
+	if ok && len(format) > 0 {
+		request.Format = ExportFormat(format[0])
+	}
+
+	columns, ok := params["columns"]
+	if ok && len(columns) > 0 {
+		request.Columns = strings.Split(columns[0], ",")
+	}
+
+	return request, request.Validate()
+}
+
+func decodeCreateRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+	if !ok {
+		return nil, errors.NewInvalidArgument("invalid parameter 'user_id'")
+	}
+
+	request := CreateRequest{UserID: id, IdempotencyKey: r.Header.Get("Idempotency-Key")}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request.Transaction); err != nil {
+		return nil, errors.NewInvalidArgument("could not decode the transaction: " + err.Error())
+	}
+
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+func decodeBulkRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	return bulkRequest{body: r.Body}, nil
+}
+
+// decodeWatchRequest reads the filters of the list endpoint. The stream resumes after the Last-Event-ID header,
+// sent by browsers when reconnecting, or the last_event_id parameter.
+func decodeWatchRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+	if !ok {
+		return nil, errors.NewInvalidArgument("invalid parameter 'user_id'")
+	}
+
+	request := WatchRequest{Query: NewTransactionQuery()}
+	request.Query.UserID = &id
+
+	params := r.URL.Query()
+	if err := DecodeFilters(params, &request.Query); err != nil {
+		return nil, err
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = params.Get("last_event_id")
+	}
+	if lastEventID != "" {
+		cursor, err := ParseCursor(lastEventID)
 		if err != nil {
-			return nil, errors.NewInvalidArgument("could not decode `date_to`")
+			return nil, errors.NewInvalidArgument("invalid header 'Last-Event-ID'")
+		}
+		request.After = cursor
+	}
+
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// decodeUpdateRequest reads the patch from the body and the version it applies to from the If-Match header,
+// which accepts the version as is or as an entity tag, like "3"
+func decodeUpdateRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	transactionRequest, err := decodeTransactionRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	request := UpdateRequest{UserID: transactionRequest.UserID, TransactionID: transactionRequest.TransactionID}
+
+	ifMatch := strings.Trim(strings.TrimPrefix(r.Header.Get("If-Match"), "W/"), `"`)
+	if ifMatch == "" {
+		return nil, errors.NewInvalidArgument("header 'If-Match' is mandatory")
+	}
+	version, err := strconv.ParseInt(ifMatch, 10, 64)
+	if err != nil {
+		return nil, errors.NewInvalidArgument("header 'If-Match' must hold the version of the transaction")
+	}
+	request.Version = version
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request.Patch); err != nil {
+		return nil, errors.NewInvalidArgument("could not decode the patch: " + err.Error())
+	}
+
+	return request, nil
+}
+
+func decodeGetByIDRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	return decodeTransactionRequest(r)
+}
+
+func decodeGetRelatedRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	return decodeTransactionRequest(r)
+}
+
+// decodeTransactionRequest reads the user and transaction IDs of the routes targeting a single transaction
+func decodeTransactionRequest(r *http.Request) (TransactionRequest, error) {
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+	if !ok {
+		return TransactionRequest{}, errors.NewInvalidArgument("invalid parameter 'user_id'")
+	}
+
+	transactionID, ok := vars["transactionID"]
+	if !ok || transactionID == "" {
+		return TransactionRequest{}, errors.NewInvalidArgument("invalid parameter 'transaction_id'")
+	}
+
+	return TransactionRequest{UserID: id, TransactionID: transactionID}, nil
+}
+
+// DecodeFilters reads the filter parameters shared by every transaction listing into the query
+func DecodeFilters(params url.Values, query *TransactionQuery) error {
+	// check `type` parameter
+	if transactionType, pTypeOk := params["type"]; pTypeOk && len(transactionType) > 0 {
+		query.Type = transactionType
+	}
+
+	// `status` can be repeated, `open` is kept as an alias on top of it
+	if status, ok := params["status"]; ok && len(status) > 0 {
+		for _, value := range status {
+			query.Status = append(query.Status, Status(value))
 		}
-		req.DateTo = &dateTo
 	}
 
 	open, ok := params["open"]
 	if ok && len(open) > 0 {
+		if query.Status != nil {
+			return errors.NewInvalidArgument("parameters 'status' and 'open' cannot be used together")
+		}
 		o, err := strconv.ParseBool(open[0])
 		if err != nil {
-			return nil, errors.NewInvalidArgument("invalid parameter 'open'")
+			return errors.NewInvalidArgument("invalid parameter 'open'")
+		}
+		if o {
+			query.Status = OpenStatuses
+		} else {
+			query.Status = SettledStatuses
 		}
-		req.Open = &o
 	}
 
-	return req, nil
-}
+	// NaN and infinities parse as floats, they are no amounts to compare with
+	amountMin, ok := params["amount_min"]
+	if ok && len(amountMin) > 0 {
+		f, err := strconv.ParseFloat(amountMin[0], 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return errors.NewInvalidArgument("invalid parameter 'amount_min'")
+		}
+		query.AmountMin = &f
+	}
 
-func decodeGetRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	params := r.URL.Query()
+	amountMax, ok := params["amount_max"]
+	if ok && len(amountMax) > 0 {
+		f, err := strconv.ParseFloat(amountMax[0], 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return errors.NewInvalidArgument("invalid parameter 'amount_max'")
+		}
+		query.AmountMax = &f
+	}
+
+	// `currency` can be repeated, codes are matched case insensitively
+	if currency, ok := params["currency"]; ok && len(currency) > 0 {
+		for _, value := range currency {
+			query.Currency = append(query.Currency, strings.ToUpper(value))
+		}
+	}
 
-	request := TransactionsRequest{}
+	text, ok := params["q"]
+	if ok && len(text) > 0 {
+		query.Text = strings.TrimSpace(text[0])
+	}
 
-	// check `type` parameter
-	if transactionType, pTypeOk := params["type"]; pTypeOk && len(transactionType) > 0 {
-		var typeArray []string
-		for _, value := range transactionType {
-			if isTypeValid(value) {
-				typeArray = append(typeArray, value)
-			} else {
-				return nil, errors.NewInvalidArgument("parameter 'type' does not match any of the accepted values")
-			}
+	fuzzy, ok := params["fuzzy"]
+	if ok && len(fuzzy) > 0 {
+		f, err := strconv.ParseBool(fuzzy[0])
+		if err != nil {
+			return errors.NewInvalidArgument("invalid parameter 'fuzzy'")
 		}
+		query.Fuzzy = f
+	}
 
-		if len(typeArray) > 0 {
-			request.Type = typeArray
+	if category, ok := params["category"]; ok && len(category) > 0 {
+		query.Category = append(query.Category, category...)
+	}
+
+	if tag, ok := params["tag"]; ok && len(tag) > 0 {
+		query.Tag = append(query.Tag, tag...)
+	}
+
+	hideDuplicates, ok := params["hide_duplicates"]
+	if ok && len(hideDuplicates) > 0 {
+		h, err := strconv.ParseBool(hideDuplicates[0])
+		if err != nil {
+			return errors.NewInvalidArgument("invalid parameter 'hide_duplicates'")
 		}
+		query.HideDuplicates = h
 	}
 
+	filter, ok := params["filter"]
+	if ok && len(filter) > 0 {
+		expr, err := ParseFilter(filter[0])
+		if err != nil {
+			return err
+		}
+		query.Filter = expr
+	}
+
+	// relative dates and periods are resolved in the requested time zone, UTC by default
+	loc := time.UTC
+	tz, ok := params["tz"]
+	if ok && len(tz) > 0 {
+		l, err := time.LoadLocation(tz[0])
+		if err != nil {
+			return errors.NewInvalidArgument("invalid parameter 'tz'")
+		}
+		loc = l
+	}
+	query.Location = loc
+	now := time.Now().In(loc)
+
 	dateFromStr, ok := params["date_from"]
-	if ok {
-		dateFrom, err := time.Parse(time.RFC3339, dateFromStr[0])
+	if ok && len(dateFromStr) > 0 {
+		dateFrom, err := ParseDate(dateFromStr[0], now)
 		if err != nil {
-			return nil, errors.NewInvalidArgument("could not decode `date_from`")
+			return errors.NewInvalidArgument("could not decode `date_from`")
 		}
-		request.DateFrom = &dateFrom
+		query.DateFrom = &dateFrom
 	}
 
 	dateToStr, ok := params["date_to"]
-	if ok {
-		dateTo, err := time.Parse(time.RFC3339, dateToStr[0])
+	if ok && len(dateToStr) > 0 {
+		dateTo, err := ParseDate(dateToStr[0], now)
 		if err != nil {
-			return nil, errors.NewInvalidArgument("could not decode `date_to`")
+			return errors.NewInvalidArgument("could not decode `date_to`")
 		}
-		request.DateTo = &dateTo
+		query.DateTo = &dateTo
 	}
 
-	if request.DateFrom == nil && request.DateTo == nil {
-		return nil, errors.NewInvalidArgument("at least one of the date range boundaries must be set")
+	period, ok := params["period"]
+	if ok && len(period) > 0 {
+		if query.DateFrom != nil || query.DateTo != nil {
+			return errors.NewInvalidArgument("parameter 'period' cannot be used with `date_from` or `date_to`")
+		}
+		dateFrom, dateTo, err := ResolvePeriod(period[0], now)
+		if err != nil {
+			return err
+		}
+		query.DateFrom = &dateFrom
+		query.DateTo = &dateTo
 	}
 
-	return request, nil
+	return nil
 }
 
-func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+func encodeExportResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	export := response.(exportResponse)
+	writer := NewExportWriter(w, export.request, export.statement)
+	return StreamExport(w, export.request.Format.ContentType(), "transactions."+string(export.request.Format), writer, export.export)
+}
+
+// StreamExport writes the transactions to the client as soon as each batch is read. Errors happening before the
+// first write are returned, so that the error encoder can report them, later ones can only cut the response short.
+func StreamExport(w http.ResponseWriter, contentType, filename string, writer ExportWriter, export func(fn func([]*Transaction) error) error) error {
+	flusher, _ := w.(http.Flusher)
+
+	started := false
+	start := func() {
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+
+	err := export(func(transactions []*Transaction) error {
+		start()
+		if err := writer.Write(transactions); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if !started {
+			return err
+		}
+		logger.LogStdErr.Error("export interrupted", zap.Error(err))
+		return nil
+	}
+
+	start()
+	return writer.Close()
+}
+
+const (
+	// sseRetry is the delay the client waits before reconnecting to a stream
+	sseRetry = 2 * time.Second
+	// sseKeepAlive is the longest silence of a stream, so that proxies do not close idle connections
+	sseKeepAlive = 15 * time.Second
+)
+
+// encodeWatchResponse streams the changes as server-sent events, the ID of an event being the cursor to resume
+// after it. The stream lasts until the client leaves or shutdown is closed.
+func encodeWatchResponse(shutdown <-chan struct{}) kithttp.EncodeResponseFunc {
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+		watch := response.(watchResponse)
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			return fmt.Errorf("streaming is not supported by the response writer")
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-shutdown:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no") // nginx must not buffer the events
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "retry: %d\n\n", sseRetry/time.Millisecond)
+		flusher.Flush()
+
+		lastWrite := time.Now()
+		err := watch.watch(ctx, func(changes []*Change) error {
+			if len(changes) == 0 {
+				if time.Since(lastWrite) < sseKeepAlive {
+					return nil
+				}
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return err
+				}
+			}
+			for _, change := range changes {
+				data, err := json.Marshal(change.Transaction)
+				if err != nil {
+					return err
+				}
+				if _, err := fmt.Fprintf(w, "id: %s\nevent: transaction\ndata: %s\n\n", change.Cursor.String(), data); err != nil {
+					return err
+				}
+			}
+			flusher.Flush()
+			lastWrite = time.Now()
+			return nil
+		})
+		// the headers are sent, an error can only end the stream
+		if err != nil && ctx.Err() == nil {
+			logger.LogStdErr.Error("stream interrupted", zap.Error(err))
+		}
+		return nil
+	}
+}
+
+func encodeCreatedResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(http.StatusCreated)
 	return json.NewEncoder(w).Encode(response)
 }
 
-func isTypeValid(transactionType string) bool {
-	return true
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(response)
 }
@@ -0,0 +1,101 @@
+package transactions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// decodeRoute decodes the request with the path variables of the routes targeting a single transaction
+func decodeRoute(r *http.Request, decode func(context.Context, *http.Request) (interface{}, error)) (interface{}, error) {
+	var decoded interface{}
+	var err error
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{id}/transactions/{transactionID}", func(w http.ResponseWriter, r *http.Request) {
+		decoded, err = decode(r.Context(), r)
+	})
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	return decoded, err
+}
+
+func TestDecodeUpdateRequestReadsTheVersion(t *testing.T) {
+	tests := []struct {
+		ifMatch string
+		version int64
+		valid   bool
+	}{
+		{`"3"`, 3, true},
+		{`W/"3"`, 3, true},
+		{`3`, 3, true},
+		{``, 0, false},
+		{`"v3"`, 0, false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPatch, "/users/u1/transactions/t1", strings.NewReader(`{"note":"checked"}`))
+		if tt.ifMatch != "" {
+			r.Header.Set("If-Match", tt.ifMatch)
+		}
+
+		decoded, err := decodeRoute(r, decodeUpdateRequest)
+		if !tt.valid {
+			if statusCode(err) != http.StatusBadRequest {
+				t.Errorf("If-Match %q: expected an invalid argument, got %v", tt.ifMatch, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("If-Match %q: %v", tt.ifMatch, err)
+			continue
+		}
+		request := decoded.(UpdateRequest)
+		if request.Version != tt.version || request.UserID != "u1" || request.TransactionID != "t1" || request.Patch.Note == nil || *request.Patch.Note != "checked" {
+			t.Errorf("If-Match %q: decoded %+v", tt.ifMatch, request)
+		}
+	}
+}
+
+func TestDecodeWatchRequestResumesAfterTheLastEventID(t *testing.T) {
+	lastEvent := changeCursor(1767225600000, "t1")
+	r := httptest.NewRequest(http.MethodGet, "/users/u1/transactions/_watch", nil)
+	r.Header.Set("Last-Event-ID", lastEvent.String())
+
+	decoded, err := decodeRoute(r, decodeWatchRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after := decoded.(WatchRequest).After; after.String() != lastEvent.String() {
+		t.Errorf("expected the stream to resume after %s, got %s", lastEvent, after)
+	}
+
+	foreign := &Cursor{Sort: DefaultSort().String(), Values: []interface{}{1767225600000, "t1"}}
+	r = httptest.NewRequest(http.MethodGet, "/users/u1/transactions/_watch?last_event_id="+foreign.String(), nil)
+	if _, err := decodeRoute(r, decodeWatchRequest); statusCode(err) != http.StatusBadRequest {
+		t.Errorf("expected the cursor of a listing to be refused, got %v", err)
+	}
+}
+
+func TestDecodeFiltersRefusesAmountsOutOfRange(t *testing.T) {
+	for _, value := range []string{"NaN", "nan", "Inf", "+Inf", "-Inf", "infinity", "1e400", "ten"} {
+		for _, param := range []string{"amount_min", "amount_max"} {
+			query := NewTransactionQuery()
+			err := DecodeFilters(url.Values{param: {value}}, &query)
+			if statusCode(err) != http.StatusBadRequest {
+				t.Errorf("%s=%s: expected an invalid argument, got %v", param, value, err)
+			}
+		}
+	}
+
+	query := NewTransactionQuery()
+	if err := DecodeFilters(url.Values{"amount_min": {"-12.5"}, "amount_max": {"1e3"}}, &query); err != nil {
+		t.Fatal(err)
+	}
+	if *query.AmountMin != -12.5 || *query.AmountMax != 1000 {
+		t.Errorf("expected the amounts between -12.5 and 1000, got %v and %v", *query.AmountMin, *query.AmountMax)
+	}
+}
@@ -4,23 +4,89 @@ import (
 	"time"
 )
 
-type TransactionsRequest struct {
-	UserID   *string    `json:"user_id"`
-	Sort     string     `json:"sort"`
-	Page     int        `json:"page"`
-	PageSize int        `json:"page_size"`
-	Type     []string   `json:"type"`
-	DateFrom *time.Time `json:"date_from"`
-	DateTo   *time.Time `json:"date_to"`
-	Open     *bool      `json:"open"`
-}
+// Types of transactions
+const (
+	TypeFee     = "fee"
+	TypeCredit  = "credit"
+	TypeRefund  = "refund"
+	TypePayment = "payment"
+	TypeInvoice = "invoice"
+	TypeReceipt = "receipt"
+)
 
 type TransactionsResponse struct {
 	Transactions []*Transaction `json:"transactions"`
 	Total        int64          `json:"total"`
+	NextCursor   string         `json:"next_cursor,omitempty"`
 }
 
-// Transaction struct (Just use Only one field)
+// TransactionRequest identifies one transaction of a user
+type TransactionRequest struct {
+	UserID        string `json:"user_id"`
+	TransactionID string `json:"transaction_id"`
+}
+
+// TransactionPage is one page of the transactions matching a query
+type TransactionPage struct {
+	Transactions []*Transaction
+	Total        int64
+	// NextCursor points right after the last transaction of the page, it is nil when there is nothing left
+	NextCursor *Cursor
+}
+
+// Transaction struct
 type Transaction struct {
-	ID string `json:"id,omitempty"`
+	ID              string          `json:"id,omitempty"`
+	UserID          string          `json:"user_id,omitempty"`
+	Type            string          `json:"type,omitempty"`
+	Status          Status          `json:"status,omitempty"`
+	Amount          float64         `json:"amount"`
+	Currency        string          `json:"currency,omitempty"`
+	Counterparty    *Counterparty   `json:"counterparty,omitempty"`
+	Description     string          `json:"description,omitempty"`
+	Reference       string          `json:"reference,omitempty"`
+	CreationDate    time.Time       `json:"creation_date"`
+	DueDate         *time.Time      `json:"due_date,omitempty"`
+	LinkedDocuments []*DocumentLink `json:"linked_documents,omitempty"`
+
+	// Category is set by the user, or else by the first rule of the user matching the transaction, see the rules
+	// package. CategoryRule is the ID of that rule.
+	Category     string `json:"category,omitempty"`
+	CategoryRule string `json:"category_rule,omitempty"`
+
+	// Tags and Note are annotations of the user
+	Tags []string `json:"tags,omitempty"`
+	Note string   `json:"note,omitempty"`
+
+	// DuplicateOf is the ID of the transaction this one was merged into as a duplicate, see the duplicates package
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+
+	// Version is the version of the stored transaction, expected by updates to detect concurrent changes
+	Version int64 `json:"version,omitempty"`
+
+	// Highlights holds, per field, the fragments that matched a full-text search
+	Highlights map[string][]string `json:"highlights,omitempty"`
+
+	// UpdatedAt is the date of the last write of the transaction, unknown for transactions stored before it was recorded
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+
+	// LastEventAt is the occurrence date of the last event applied to the transaction by the events consumer. The
+	// writes of a transaction which was not read from the repository clear it.
+	LastEventAt *time.Time `json:"-"`
+
+	// IdempotencyFingerprint identifies the payload the transaction was created from through the API
+	IdempotencyFingerprint string `json:"-"`
+}
+
+// Counterparty is the other party of a transaction (merchant, customer, bank...)
+type Counterparty struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+	IBAN string `json:"iban,omitempty"`
+}
+
+// DocumentLink references another document (invoice, payment, receipt...) related to a transaction
+type DocumentLink struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
 }
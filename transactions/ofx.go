@@ -0,0 +1,107 @@
+package transactions
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ofxTransactionTypes maps the types of the transactions moving money to the OFX TRNTYPE values
+var ofxTransactionTypes = map[string]string{
+	TypeFee:     "FEE",
+	TypeCredit:  "CREDIT",
+	TypeRefund:  "CREDIT",
+	TypePayment: "PAYMENT",
+}
+
+// ofxWriter writes an OFX 2.1.1 bank statement of the booked transactions, see IsBooked. The closing balance is reported as the ledger balance and the
+// opening balance, which has no dedicated OFX element, in the list of additional balances.
+type ofxWriter struct {
+	w             *bufio.Writer
+	statement     *Statement
+	headerWritten bool
+}
+
+func newOFXWriter(w io.Writer, statement *Statement) *ofxWriter {
+	return &ofxWriter{w: bufio.NewWriter(w), statement: statement}
+}
+
+func ofxDate(t time.Time) string {
+	return t.UTC().Format("20060102150405.000") + "[0:GMT]"
+}
+
+func ofxAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// ofxText escapes a value and truncates it to the maximum length of the element
+func ofxText(value string, maxLength int) string {
+	runes := []rune(value)
+	if len(runes) > maxLength {
+		runes = runes[:maxLength]
+	}
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(string(runes)))
+	return buf.String()
+}
+
+func (o *ofxWriter) writeHeader() {
+	if o.headerWritten {
+		return
+	}
+	o.headerWritten = true
+
+	s := o.statement
+	fmt.Fprint(o.w, `<?xml version="1.0" encoding="UTF-8" standalone="no"?>`+"\n")
+	fmt.Fprint(o.w, `<?OFX OFXHEADER="200" VERSION="211" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>`+"\n")
+	fmt.Fprint(o.w, "<OFX>\n")
+	fmt.Fprintf(o.w, "<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS><DTSERVER>%s</DTSERVER><LANGUAGE>ENG</LANGUAGE></SONRS></SIGNONMSGSRSV1>\n", ofxDate(time.Now()))
+	fmt.Fprint(o.w, "<BANKMSGSRSV1><STMTTRNRS><TRNUID>0</TRNUID><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>\n")
+	fmt.Fprintf(o.w, "<STMTRS><CURDEF>%s</CURDEF>\n", s.Currency)
+	fmt.Fprintf(o.w, "<BANKACCTFROM><BANKID>%s</BANKID><ACCTID>%s</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE></BANKACCTFROM>\n", ofxText(s.Issuer.BankID, 9), ofxText(s.AccountID, 22))
+	fmt.Fprintf(o.w, "<BANKTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>\n", ofxDate(s.From), ofxDate(s.To))
+}
+
+func (o *ofxWriter) Write(transactions []*Transaction) error {
+	o.writeHeader()
+
+	for _, t := range transactions {
+		if !IsBooked(t) {
+			continue
+		}
+		trnType := ofxTransactionTypes[t.Type]
+
+		fmt.Fprintf(o.w, "<STMTTRN><TRNTYPE>%s</TRNTYPE><DTPOSTED>%s</DTPOSTED>", trnType, ofxDate(t.CreationDate))
+		if t.DueDate != nil {
+			fmt.Fprintf(o.w, "<DTAVAIL>%s</DTAVAIL>", ofxDate(*t.DueDate))
+		}
+		fmt.Fprintf(o.w, "<TRNAMT>%s</TRNAMT><FITID>%s</FITID>", ofxAmount(SignedAmount(t)), ofxText(t.ID, 255))
+		if t.Reference != "" {
+			fmt.Fprintf(o.w, "<REFNUM>%s</REFNUM>", ofxText(t.Reference, 32))
+		}
+		if t.Counterparty != nil && t.Counterparty.Name != "" {
+			fmt.Fprintf(o.w, "<NAME>%s</NAME>", ofxText(t.Counterparty.Name, 32))
+		}
+		if t.Description != "" {
+			fmt.Fprintf(o.w, "<MEMO>%s</MEMO>", ofxText(t.Description, 255))
+		}
+		fmt.Fprint(o.w, "</STMTTRN>\n")
+	}
+	return o.w.Flush()
+}
+
+func (o *ofxWriter) Close() error {
+	o.writeHeader()
+
+	s := o.statement
+	fmt.Fprint(o.w, "</BANKTRANLIST>\n")
+	fmt.Fprintf(o.w, "<LEDGERBAL><BALAMT>%s</BALAMT><DTASOF>%s</DTASOF></LEDGERBAL>\n", ofxAmount(s.ClosingBalance), ofxDate(s.To))
+	fmt.Fprintf(o.w, "<BALLIST><BAL><NAME>Opening balance</NAME><DESC>Balance at the start of the period</DESC><BALTYPE>DOLLAR</BALTYPE><VALUE>%s</VALUE><DTASOF>%s</DTASOF></BAL></BALLIST>\n", ofxAmount(s.OpeningBalance), ofxDate(s.From))
+	fmt.Fprint(o.w, "</STMTRS></STMTTRNRS></BANKMSGSRSV1>\n")
+	fmt.Fprint(o.w, "</OFX>\n")
+	return o.w.Flush()
+}
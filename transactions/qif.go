@@ -0,0 +1,71 @@
+package transactions
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// qifWriter writes a QIF bank account of the booked transactions, see IsBooked. QIF has no notion of closing balance: importers compute it from the
+// opening balance, which is exported as the first record as Quicken does.
+type qifWriter struct {
+	w             *bufio.Writer
+	statement     *Statement
+	headerWritten bool
+}
+
+func newQIFWriter(w io.Writer, statement *Statement) *qifWriter {
+	return &qifWriter{w: bufio.NewWriter(w), statement: statement}
+}
+
+func qifDate(t time.Time) string {
+	return t.Format("01/02/2006")
+}
+
+// qifText keeps a value on a single line, QIF fields are line based
+func qifText(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+func (q *qifWriter) writeHeader() {
+	if q.headerWritten {
+		return
+	}
+	q.headerWritten = true
+
+	s := q.statement
+	fmt.Fprint(q.w, "!Type:Bank\n")
+	fmt.Fprintf(q.w, "D%s\nT%s\nPOpening Balance\nL[%s]\n^\n", qifDate(s.From), ofxAmount(s.OpeningBalance), qifText(s.AccountID))
+}
+
+func (q *qifWriter) Write(transactions []*Transaction) error {
+	q.writeHeader()
+
+	for _, t := range transactions {
+		if !IsBooked(t) {
+			continue
+		}
+		fmt.Fprintf(q.w, "D%s\nT%s\n", qifDate(t.CreationDate), ofxAmount(SignedAmount(t)))
+		if t.Reference != "" {
+			fmt.Fprintf(q.w, "N%s\n", qifText(t.Reference))
+		}
+		if t.Counterparty != nil && t.Counterparty.Name != "" {
+			fmt.Fprintf(q.w, "P%s\n", qifText(t.Counterparty.Name))
+		}
+		if t.Description != "" {
+			fmt.Fprintf(q.w, "M%s\n", qifText(t.Description))
+		}
+		if t.Status == StatusPaid {
+			fmt.Fprint(q.w, "CX\n")
+		}
+		fmt.Fprint(q.w, "^\n")
+	}
+	return q.w.Flush()
+}
+
+func (q *qifWriter) Close() error {
+	q.writeHeader()
+	return q.w.Flush()
+}
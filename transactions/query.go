@@ -0,0 +1,133 @@
+package transactions
+
+import (
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+)
+
+// TransactionQuery describes which transactions to fetch (filters), in which order (sort) and which slice of
+// them (pagination). It is built by the transport layer and flows unchanged down to the repository.
+type TransactionQuery struct {
+	// Filters
+	UserID   *string    `json:"user_id"`
+	Type     []string   `json:"type"`
+	DateFrom *time.Time `json:"date_from"`
+	DateTo   *time.Time `json:"date_to"`
+	Status   []Status   `json:"status"`
+
+	// Location is the time zone relative dates were resolved in, calendar buckets are computed in it too
+	Location *time.Location `json:"-"`
+
+	AmountMin *float64 `json:"amount_min"`
+	AmountMax *float64 `json:"amount_max"`
+	Currency  []string `json:"currency"`
+
+	// Text is matched against description, counterparty name and reference, with typo tolerance when Fuzzy is set
+	Text  string `json:"q"`
+	Fuzzy bool   `json:"fuzzy"`
+
+	// Filter is an advanced filter expression, see ParseFilter, combined with the other filters
+	Filter FilterExpr `json:"-"`
+
+	// Category keeps the transactions categorized as one of the values by the rules of the user. Categories are
+	// not stored, the rules service turns them into a Filter before the query reaches the repository.
+	Category []string `json:"category"`
+
+	// Tag keeps the transactions annotated with at least one of the tags
+	Tag []string `json:"tag"`
+
+	// HideDuplicates leaves out the transactions merged into another one as duplicates
+	HideDuplicates bool `json:"hide_duplicates"`
+
+	// ExcludedStatus leaves out the transactions having one of the statuses, it is only set by the services
+	ExcludedStatus []Status `json:"-"`
+
+	// Sort, the repository always adds a tie-breaker on the ID to keep pages stable
+	Sort SortSpec `json:"sort"`
+
+	// Pagination, a PageSize of 0 lets the repository pick its default size.
+	// When a Cursor is set, the page starts right after it and Page is ignored.
+	Page     int     `json:"page"`
+	PageSize int     `json:"page_size"`
+	Cursor   *Cursor `json:"cursor"`
+}
+
+// NewTransactionQuery returns a query initialized with the default sort and pagination
+func NewTransactionQuery() TransactionQuery {
+	return TransactionQuery{
+		Sort: DefaultSort(),
+		Page: 1,
+	}
+}
+
+// Validate checks that the query is consistent, it returns an InvalidArgument error otherwise
+func (q TransactionQuery) Validate() error {
+	for _, value := range q.Type {
+		if !IsTypeValid(value) {
+			return errors.NewInvalidArgument("parameter 'type' does not match any of the accepted values")
+		}
+	}
+
+	for _, value := range q.Status {
+		if !value.IsValid() {
+			return errors.NewInvalidArgument("parameter 'status' does not match any of the accepted values")
+		}
+	}
+
+	if q.DateFrom != nil && q.DateTo != nil && q.DateTo.Before(*q.DateFrom) {
+		return errors.NewInvalidArgument("`date_from` must be before `date_to`")
+	}
+
+	if q.AmountMin != nil && q.AmountMax != nil && *q.AmountMin > *q.AmountMax {
+		return errors.NewInvalidArgument("`amount_min` must be lower than or equal to `amount_max`")
+	}
+
+	for _, value := range q.Currency {
+		if !isCurrencyValid(value) {
+			return errors.NewInvalidArgument("parameter 'currency' must be an ISO 4217 code")
+		}
+	}
+
+	if len(q.Sort) == 0 {
+		return errors.NewInvalidArgument("invalid parameter 'sort'")
+	}
+	for _, field := range q.Sort {
+		if !sortableFields[field.Field] {
+			return errors.NewInvalidArgument("invalid parameter 'sort'")
+		}
+	}
+
+	if q.Page < 1 {
+		return errors.NewInvalidArgument("invalid parameter 'page'")
+	}
+
+	if q.PageSize < 0 {
+		return errors.NewInvalidArgument("invalid parameter 'page_size'")
+	}
+
+	// a cursor holds one value per sort criterion plus the tie-breaker
+	if q.Cursor != nil && (q.Cursor.Sort != q.Sort.String() || len(q.Cursor.Values) != len(q.Sort)+1) {
+		return errors.NewInvalidArgument("parameter 'cursor' was built for another sort")
+	}
+
+	return nil
+}
+
+// IsTypeValid tells whether the type is one of the known ones, see knownTypes
+func IsTypeValid(transactionType string) bool {
+	return knownTypes[transactionType]
+}
+
+// isCurrencyValid checks the shape of an ISO 4217 code: three upper case letters
+func isCurrencyValid(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+	for _, c := range currency {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
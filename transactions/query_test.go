@@ -0,0 +1,21 @@
+package transactions
+
+import "testing"
+
+func TestQueryValidateType(t *testing.T) {
+	for _, tt := range []struct {
+		types   []string
+		wantErr bool
+	}{
+		{[]string{TypeFee}, false},
+		{[]string{TypeInvoice, TypePayment}, false},
+		{[]string{"fees"}, true},
+		{[]string{TypeFee, ""}, true},
+	} {
+		query := NewTransactionQuery()
+		query.Type = tt.types
+		if err := query.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("types %q: error %v, want an error: %v", tt.types, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package transactions
+
+import (
+	"math"
+	"sort"
+)
+
+// maxRelatedDocuments bounds the number of documents gathered when following links
+const maxRelatedDocuments = 100
+
+// chainRank orders the documents created at the same time: an invoice comes before its payments, and so on
+var chainRank = map[string]int{
+	TypeInvoice: 0,
+	TypePayment: 1,
+	TypeCredit:  2,
+	TypeReceipt: 3,
+	TypeRefund:  4,
+}
+
+// RelatedDocuments is the chain of documents linked, directly or not, to a transaction
+type RelatedDocuments struct {
+	TransactionID string       `json:"transaction_id"`
+	Chain         []*ChainStep `json:"chain"`
+}
+
+// ChainStep is one document of the chain with the amount still due once it is taken into account
+type ChainStep struct {
+	Transaction *Transaction `json:"transaction"`
+	Outstanding float64      `json:"outstanding"`
+}
+
+// linkedIDs returns the IDs referenced by the transaction which were not found yet
+func linkedIDs(transaction *Transaction, found map[string]*Transaction) []string {
+	var ids []string
+	for _, link := range transaction.LinkedDocuments {
+		if _, ok := found[link.ID]; !ok {
+			ids = append(ids, link.ID)
+		}
+	}
+	return ids
+}
+
+// buildChain orders the documents chronologically and computes the outstanding amount after each of them:
+// invoices and refunds raise it, payments and credits lower it, receipts leave it untouched
+func buildChain(found map[string]*Transaction) []*ChainStep {
+	documents := make([]*Transaction, 0, len(found))
+	for _, transaction := range found {
+		documents = append(documents, transaction)
+	}
+	sort.Slice(documents, func(i, j int) bool {
+		if !documents[i].CreationDate.Equal(documents[j].CreationDate) {
+			return documents[i].CreationDate.Before(documents[j].CreationDate)
+		}
+		if chainRank[documents[i].Type] != chainRank[documents[j].Type] {
+			return chainRank[documents[i].Type] < chainRank[documents[j].Type]
+		}
+		return documents[i].ID < documents[j].ID
+	})
+
+	chain := make([]*ChainStep, len(documents))
+	outstanding := 0.0
+	for i, transaction := range documents {
+		switch transaction.Type {
+		case TypeInvoice, TypeRefund:
+			outstanding += math.Abs(transaction.Amount)
+		case TypePayment, TypeCredit:
+			outstanding -= math.Abs(transaction.Amount)
+		}
+		chain[i] = &ChainStep{Transaction: transaction, Outstanding: outstanding}
+	}
+	return chain
+}
@@ -5,8 +5,43 @@ import (
 	"time"
 )
 
+// OwnedError is implemented by the errors about a stored transaction which can tell the user it belongs to, the
+// owner being empty when unknown
+type OwnedError interface {
+	error
+	Owner() string
+}
+
 // Repository interface
 type Repository interface {
-	GetByUser(ctx context.Context, userID string, transactionType []string, sort string, page, pageSize int, dateFrom, dateTo *time.Time, open *bool) ([]*Transaction, int64, error)
-	GetByDateRange(ctx context.Context, transactionType []string, dateFrom, dateTo *time.Time) ([]*Transaction, int64, error)
+	GetByUser(ctx context.Context, query TransactionQuery) (*TransactionPage, error)
+	// GetByDateRange loads every matching transaction in memory, Stream should be preferred for wide ranges
+	GetByDateRange(ctx context.Context, query TransactionQuery) ([]*Transaction, int64, error)
+	GetSummaryByUser(ctx context.Context, request SummaryRequest) (*TransactionsSummary, error)
+	// GetByID returns a NotFound error when there is no transaction with this ID, and an OwnedError when the stored
+	// transaction can not be read
+	GetByID(ctx context.Context, transactionID string) (*Transaction, error)
+	// GetByIDs returns the stored transactions having one of the IDs, the missing ones being left out. Unlike a
+	// search, it reads the transactions just written too.
+	GetByIDs(ctx context.Context, transactionIDs []string) ([]*Transaction, error)
+	// GetRelated returns the transactions of the user having one of the IDs or linking to one of them
+	GetRelated(ctx context.Context, userID string, transactionIDs []string) ([]*Transaction, error)
+	// Stream calls fn with the transactions matching the query, one batch at a time, in the order of the sort of
+	// the query. It stops at the first error returned by fn or when ctx is done, and releases the resources held on
+	// the backend in every case.
+	Stream(ctx context.Context, query TransactionQuery, fn func([]*Transaction) error) error
+	// Create stores a new transaction, it returns ErrTransactionExists when the ID is already used
+	Create(ctx context.Context, transaction *Transaction) error
+	// Bulk stores the transactions, updating those with an existing ID like Save does. A transaction whose ID is
+	// used by another user fails. The results follow the order of the transactions, a transaction without ID gets
+	// one generated.
+	Bulk(ctx context.Context, transactions []*Transaction) ([]*BulkItemResult, error)
+	// Save stores the transaction, overwriting it when it already exists
+	Save(ctx context.Context, transaction *Transaction) error
+	// Update applies the patch when the stored transaction is still at the given version, and fails with a
+	// conflict otherwise
+	Update(ctx context.Context, transactionID string, patch TransactionPatch, version int64) error
+	// GetChanges returns, by ascending update date, at most size transactions updated after the cursor and before
+	// until, with the cursor of each of them
+	GetChanges(ctx context.Context, query TransactionQuery, after *Cursor, until time.Time, size int) ([]*Change, error)
 }
@@ -1,31 +1,369 @@
 package transactions
 
 import (
+	"bufio"
 	"context"
+	"fmt"
+	"io"
+	"sort"
 	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
 )
 
 // Service is the transaction service interface
 type Service interface {
-	GetByUser(ctx context.Context, userID string, transactionType []string, sort string, page, pageSize int, dateFrom, dateTo *time.Time, open *bool) ([]*Transaction, int64, error)
-	GetByDateRange(ctx context.Context, transactionType []string, dateFrom, dateTo *time.Time) ([]*Transaction, int64, error)
+	GetByUser(ctx context.Context, query TransactionQuery) (*TransactionPage, error)
+	GetByDateRange(ctx context.Context, query TransactionQuery) ([]*Transaction, int64, error)
+	GetSummaryByUser(ctx context.Context, request SummaryRequest) (*TransactionsSummary, error)
+	GetByID(ctx context.Context, request TransactionRequest) (*Transaction, error)
+	GetRelated(ctx context.Context, request TransactionRequest) (*RelatedDocuments, error)
+	Stream(ctx context.Context, query TransactionQuery, fn func([]*Transaction) error) error
+	Export(ctx context.Context, request ExportRequest, fn func([]*Transaction) error) error
+	GetStatement(ctx context.Context, query TransactionQuery) (*Statement, error)
+	Create(ctx context.Context, request CreateRequest) (*Transaction, error)
+	Bulk(ctx context.Context, body io.Reader) (*BulkReport, error)
+	Watch(ctx context.Context, request WatchRequest, fn func([]*Change) error) error
+	Update(ctx context.Context, request UpdateRequest) (*Transaction, error)
 }
 
 type service struct {
-	repo Repository
+	repo          Repository
+	issuer        StatementIssuer
+	bulkBatchSize int
 }
 
-// NewService initializes new service
-func NewService(repo Repository) (Service, error) {
+// NewService initializes new service, bulkBatchSize is the number of transactions sent at once by Bulk
+func NewService(repo Repository, issuer StatementIssuer, bulkBatchSize int) (Service, error) {
+	if bulkBatchSize < 1 {
+		return nil, fmt.Errorf("invalid bulk batch size %d", bulkBatchSize)
+	}
 	return &service{
-		repo: repo,
+		repo:          repo,
+		issuer:        issuer,
+		bulkBatchSize: bulkBatchSize,
 	}, nil
 }
 
-func (s *service) GetByUser(ctx context.Context, userID string, transactionType []string, sort string, page, pageSize int, dateFrom, dateTo *time.Time, open *bool) ([]*Transaction, int64, error) {
-	return s.repo.GetByUser(ctx, userID, transactionType, sort, page, pageSize, dateFrom, dateTo, open)
+func (s *service) GetByUser(ctx context.Context, query TransactionQuery) (*TransactionPage, error) {
+	if query.UserID == nil {
+		return nil, errors.NewInvalidArgument("invalid parameter 'user_id'")
+	}
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByUser(ctx, query)
+}
+
+func (s *service) GetByDateRange(ctx context.Context, query TransactionQuery) ([]*Transaction, int64, error) {
+	if query.DateFrom == nil && query.DateTo == nil {
+		return nil, 0, errors.NewInvalidArgument("at least one of the date range boundaries must be set")
+	}
+	if err := query.Validate(); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.GetByDateRange(ctx, query)
+}
+
+// Stream calls fn with the matching transactions as they are read, so that memory use does not depend on their number
+func (s *service) Stream(ctx context.Context, query TransactionQuery, fn func([]*Transaction) error) error {
+	if query.UserID == nil && query.DateFrom == nil && query.DateTo == nil {
+		return errors.NewInvalidArgument("at least one of the date range boundaries must be set")
+	}
+	if err := query.Validate(); err != nil {
+		return err
+	}
+	return s.repo.Stream(ctx, query, fn)
+}
+
+func (s *service) Export(ctx context.Context, request ExportRequest, fn func([]*Transaction) error) error {
+	if err := request.Validate(); err != nil {
+		return err
+	}
+	return s.Stream(ctx, request.Query, fn)
+}
+
+// GetStatement computes the balances at the boundaries of the period, the period ending now when the query has
+// no `date_to`
+func (s *service) GetStatement(ctx context.Context, query TransactionQuery) (*Statement, error) {
+	if err := validateStatement(query); err != nil {
+		return nil, err
+	}
+
+	to := time.Now()
+	if query.DateTo != nil {
+		to = *query.DateTo
+	}
+	statement := &Statement{
+		Issuer:    s.issuer,
+		AccountID: *query.UserID,
+		Currency:  query.Currency[0],
+		From:      *query.DateFrom,
+		To:        to,
+	}
+
+	var err error
+	if statement.OpeningBalance, err = s.getBalance(ctx, query, statement.From); err != nil {
+		return nil, err
+	}
+	if statement.ClosingBalance, err = s.getBalance(ctx, query, statement.To); err != nil {
+		return nil, err
+	}
+	return statement, nil
+}
+
+// getBalance sums up the booked transactions matching the filters of the statement query created before the
+// date, the same transactions as the entries of the statement (see IsBooked). An entry counts its amount whatever
+// its sign, so the transactions stored with a positive amount and the ones stored with a negative amount are
+// summed up apart.
+func (s *service) getBalance(ctx context.Context, query TransactionQuery, at time.Time) (float64, error) {
+	query.DateFrom = nil
+	query.DateTo = &at
+	query.Cursor = nil
+	if len(query.Status) > 0 {
+		query.Status = bookedStatuses(query.Status)
+		if len(query.Status) == 0 {
+			return 0, nil
+		}
+	}
+	query.ExcludedStatus = []Status{StatusCancelled}
+
+	zero := 0.0
+	positive, negative := query, query
+	if positive.AmountMin == nil || *positive.AmountMin < 0 {
+		positive.AmountMin = &zero
+	}
+	if negative.AmountMax == nil || *negative.AmountMax > 0 {
+		negative.AmountMax = &zero
+	}
+
+	positiveBalance, err := s.summaryBalance(ctx, positive)
+	if err != nil {
+		return 0, err
+	}
+	negativeBalance, err := s.summaryBalance(ctx, negative)
+	if err != nil {
+		return 0, err
+	}
+	// the negative amounts count as their opposite, like in SignedAmount
+	return positiveBalance - negativeBalance, nil
+}
+
+// summaryBalance computes the balance of the transactions matching the query from their summary by type
+func (s *service) summaryBalance(ctx context.Context, query TransactionQuery) (float64, error) {
+	summary, err := s.repo.GetSummaryByUser(ctx, SummaryRequest{Query: query, Interval: IntervalMonth})
+	if err != nil {
+		return 0, err
+	}
+	return balanceAt(summary, query.Currency[0]), nil
+}
+
+func (s *service) GetSummaryByUser(ctx context.Context, request SummaryRequest) (*TransactionsSummary, error) {
+	if request.Query.UserID == nil {
+		return nil, errors.NewInvalidArgument("invalid parameter 'user_id'")
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	return s.repo.GetSummaryByUser(ctx, request)
+}
+
+func (s *service) GetByID(ctx context.Context, request TransactionRequest) (*Transaction, error) {
+	transaction, err := s.repo.GetByID(ctx, request.TransactionID)
+	if owned, ok := err.(OwnedError); ok && owned.Owner() != request.UserID {
+		// an unreadable transaction is reported as missing too, unless it is known to be the user's
+		return nil, errors.NewNotFoundError("transaction")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// a transaction of another user is reported as missing, not to leak its existence
+	if transaction.UserID != request.UserID {
+		return nil, errors.NewNotFoundError("transaction")
+	}
+	return transaction, nil
+}
+
+func (s *service) GetRelated(ctx context.Context, request TransactionRequest) (*RelatedDocuments, error) {
+	origin, err := s.GetByID(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[string]*Transaction{origin.ID: origin}
+	frontier := append([]string{origin.ID}, linkedIDs(origin, found)...)
+
+	// walk the links both ways, one hop at a time, until no new document shows up
+	for len(frontier) > 0 && len(found) < maxRelatedDocuments {
+		related, err := s.repo.GetRelated(ctx, request.UserID, frontier)
+		if err != nil {
+			return nil, err
+		}
+
+		var next []string
+		for _, transaction := range related {
+			if _, ok := found[transaction.ID]; ok {
+				continue
+			}
+			found[transaction.ID] = transaction
+			next = append(next, transaction.ID)
+		}
+		for _, id := range next {
+			next = append(next, linkedIDs(found[id], found)...)
+		}
+		frontier = next
+	}
+
+	return &RelatedDocuments{TransactionID: origin.ID, Chain: buildChain(found)}, nil
+}
+
+// Create stores the transaction once per idempotency key: a retry returns the transaction created the first
+// time, while reusing the key for another payload is a conflict
+func (s *service) Create(ctx context.Context, request CreateRequest) (*Transaction, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	transaction := request.toTransaction(time.Now())
+	err := s.repo.Create(ctx, transaction)
+	if err == nil {
+		return transaction, nil
+	}
+	if err != ErrTransactionExists {
+		return nil, err
+	}
+
+	existing, err := s.repo.GetByID(ctx, transaction.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing.IdempotencyFingerprint != transaction.IdempotencyFingerprint {
+		return nil, errors.NewConflictError("idempotency key already used for another transaction")
+	}
+	return existing, nil
+}
+
+// Bulk stores the transactions of an NDJSON body, one per line, in batches. Invalid lines and transactions
+// refused by the repository are reported without preventing the other lines from being stored.
+func (s *service) Bulk(ctx context.Context, body io.Reader) (*BulkReport, error) {
+	report := &BulkReport{Items: []*BulkItemResult{}}
+	now := time.Now()
+
+	var batch []*Transaction
+	var batchLines []int
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		results, err := s.repo.Bulk(ctx, batch)
+		for i, line := range batchLines {
+			item := &BulkItemResult{Line: line, ID: batch[i].ID, Result: BulkFailed}
+			if err != nil {
+				item.Error = err.Error()
+			} else {
+				item.ID, item.Result, item.Error = results[i].ID, results[i].Result, results[i].Error
+			}
+			report.add(item)
+		}
+		batch, batchLines = batch[:0], batchLines[:0]
+	}
+
+	reader := bufio.NewReader(body)
+	for line := 1; ; line++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		content, tooLong, err := readBulkLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if tooLong {
+			report.add(&BulkItemResult{Line: line, Result: BulkFailed, Error: fmt.Sprintf("line is longer than %d bytes", maxBulkLineLength)})
+			continue
+		}
+		if len(content) == 0 {
+			continue
+		}
+
+		transaction, err := decodeBulkLine(content, now)
+		if err != nil {
+			report.add(&BulkItemResult{Line: line, Result: BulkFailed, Error: err.Error()})
+			continue
+		}
+
+		batch = append(batch, transaction)
+		batchLines = append(batchLines, line)
+		if len(batch) == s.bulkBatchSize {
+			flush()
+		}
+	}
+	flush()
+
+	// invalid lines are reported before the batch they belong to is stored
+	sort.Slice(report.Items, func(i, j int) bool { return report.Items[i].Line < report.Items[j].Line })
+	return report, nil
+}
+
+// Watch polls the repository for changes and calls fn with each batch of them, an empty batch meaning nothing
+// changed since the previous poll, until ctx is done
+func (s *service) Watch(ctx context.Context, request WatchRequest, fn func([]*Change) error) error {
+	if err := request.Validate(); err != nil {
+		return err
+	}
+
+	cursor := request.After
+	if cursor == nil {
+		cursor = startCursor(time.Now())
+	}
+
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+
+	for {
+		batch, err := s.repo.GetChanges(ctx, request.Query, cursor, time.Now().Add(-watchSettleDelay), watchBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) > 0 {
+			cursor = batch[len(batch)-1].Cursor
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		// a full batch means more changes are waiting
+		if len(batch) == watchBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
 }
 
-func (s *service) GetByDateRange(ctx context.Context, transactionType []string, dateFrom, dateTo *time.Time) ([]*Transaction, int64, error) {
-	return s.repo.GetByDateRange(ctx, transactionType, dateFrom, dateTo)
+// Update patches the annotations of the transaction and returns it at its new version
+func (s *service) Update(ctx context.Context, request UpdateRequest) (*Transaction, error) {
+	if request.Patch.Tags != nil {
+		tags := NormalizeTags(*request.Patch.Tags)
+		request.Patch.Tags = &tags
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.GetByID(ctx, TransactionRequest{UserID: request.UserID, TransactionID: request.TransactionID}); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(ctx, request.TransactionID, request.Patch, request.Version); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(ctx, request.TransactionID)
 }
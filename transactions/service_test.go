@@ -0,0 +1,259 @@
+package transactions
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+)
+
+// memoryRepository stores the transactions by ID, a write increments their version
+type memoryRepository struct {
+	Repository
+	stored map[string]*Transaction
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{stored: make(map[string]*Transaction)}
+}
+
+func (r *memoryRepository) Create(ctx context.Context, transaction *Transaction) error {
+	if _, ok := r.stored[transaction.ID]; ok {
+		return ErrTransactionExists
+	}
+	stored := *transaction
+	stored.Version = 1
+	r.stored[transaction.ID] = &stored
+	return nil
+}
+
+func (r *memoryRepository) GetByID(ctx context.Context, transactionID string) (*Transaction, error) {
+	transaction, ok := r.stored[transactionID]
+	if !ok {
+		return nil, errors.NewNotFoundError("transaction")
+	}
+	copied := *transaction
+	return &copied, nil
+}
+
+func (r *memoryRepository) Update(ctx context.Context, transactionID string, patch TransactionPatch, version int64) error {
+	transaction, ok := r.stored[transactionID]
+	if !ok {
+		return errors.NewNotFoundError("transaction")
+	}
+	if transaction.Version != version {
+		return errors.NewConflictError("the transaction was modified")
+	}
+	if patch.Tags != nil {
+		transaction.Tags = *patch.Tags
+	}
+	if patch.Note != nil {
+		transaction.Note = *patch.Note
+	}
+	transaction.Version++
+	return nil
+}
+
+// unreadableError is the error of a stored transaction which can not be read, owned by the user
+type unreadableError string
+
+func (e unreadableError) Error() string { return "unreadable transaction" }
+func (e unreadableError) Owner() string { return string(e) }
+
+// unreadableRepository fails to read every transaction with the error
+type unreadableRepository struct {
+	Repository
+	err error
+}
+
+func (r *unreadableRepository) GetByID(ctx context.Context, transactionID string) (*Transaction, error) {
+	return nil, r.err
+}
+
+// changesRepository returns the scripted batches of changes, one per lookup, recording the cursors looked up after
+type changesRepository struct {
+	Repository
+	batches [][]*Change
+	after   []*Cursor
+}
+
+func (r *changesRepository) GetChanges(ctx context.Context, query TransactionQuery, after *Cursor, until time.Time, size int) ([]*Change, error) {
+	r.after = append(r.after, after)
+	if len(r.after) > len(r.batches) {
+		return nil, nil
+	}
+	return r.batches[len(r.after)-1], nil
+}
+
+// changeCursor is the cursor of a change of the transaction updated at the epoch millis
+func changeCursor(millis int64, id string) *Cursor {
+	return &Cursor{Sort: ChangesSort().String(), Values: []interface{}{millis, id}}
+}
+
+// statusCode returns the HTTP status an error is reported with, 0 for a plain error
+func statusCode(err error) int {
+	if coded, ok := err.(interface{ StatusCode() int }); ok {
+		return coded.StatusCode()
+	}
+	return 0
+}
+
+func newTestService(t *testing.T, repo Repository) Service {
+	s, err := NewService(repo, StatementIssuer{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCreateIsIdempotent(t *testing.T) {
+	repo := newMemoryRepository()
+	s := newTestService(t, repo)
+	ctx := context.Background()
+	created := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	payload := NewTransaction{Type: TypeFee, Amount: 12, Currency: "EUR", CreationDate: &created}
+
+	first, err := s.Create(ctx, CreateRequest{UserID: "u1", IdempotencyKey: "key-1", Transaction: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a retry returns the transaction created first
+	retried, err := s.Create(ctx, CreateRequest{UserID: "u1", IdempotencyKey: "key-1", Transaction: payload})
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if retried.ID != first.ID || len(repo.stored) != 1 {
+		t.Errorf("expected the retry to return %s without creating anything, got %s and %d transactions", first.ID, retried.ID, len(repo.stored))
+	}
+
+	// the key of another transaction can not be reused
+	other := payload
+	other.Amount = 13
+	if _, err := s.Create(ctx, CreateRequest{UserID: "u1", IdempotencyKey: "key-1", Transaction: other}); statusCode(err) != http.StatusConflict {
+		t.Errorf("expected a conflict when reusing the key for another payload, got %v", err)
+	}
+
+	// the keys of the users are distinct
+	second, err := s.Create(ctx, CreateRequest{UserID: "u2", IdempotencyKey: "key-1", Transaction: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.ID == first.ID || second.UserID != "u2" || len(repo.stored) != 2 {
+		t.Errorf("expected the same key of another user to create another transaction, got %s for %s", second.ID, second.UserID)
+	}
+}
+
+func TestUpdateRefusesAStaleVersion(t *testing.T) {
+	repo := newMemoryRepository()
+	repo.stored["t1"] = &Transaction{ID: "t1", UserID: "u1", Version: 3}
+	s := newTestService(t, repo)
+	ctx := context.Background()
+	note := "checked"
+
+	updated, err := s.Update(ctx, UpdateRequest{UserID: "u1", TransactionID: "t1", Version: 3, Patch: TransactionPatch{Note: &note}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Note != note || updated.Version != 4 {
+		t.Errorf("expected the patched transaction at version 4, got %+v", updated)
+	}
+
+	// the patch was made on the version before the update
+	other := "other"
+	if _, err := s.Update(ctx, UpdateRequest{UserID: "u1", TransactionID: "t1", Version: 3, Patch: TransactionPatch{Note: &other}}); statusCode(err) != http.StatusConflict {
+		t.Errorf("expected a conflict on a stale version, got %v", err)
+	}
+	if repo.stored["t1"].Note != note {
+		t.Errorf("expected the stale patch to be left out, got %q", repo.stored["t1"].Note)
+	}
+
+	if _, err := s.Update(ctx, UpdateRequest{UserID: "u2", TransactionID: "t1", Version: 4, Patch: TransactionPatch{Note: &other}}); statusCode(err) != http.StatusNotFound {
+		t.Errorf("expected the transaction of another user to be missing, got %v", err)
+	}
+}
+
+func TestWatchDrainsTheChangesAfterTheLastEvent(t *testing.T) {
+	full := make([]*Change, watchBatchSize)
+	for i := range full {
+		full[i] = &Change{Transaction: &Transaction{ID: "t"}, Cursor: changeCursor(int64(i+1), "t")}
+	}
+	last := &Change{Transaction: &Transaction{ID: "u"}, Cursor: changeCursor(int64(watchBatchSize+1), "u")}
+	repo := &changesRepository{batches: [][]*Change{full, {last}}}
+	s := newTestService(t, repo)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	userID := "u1"
+	query := NewTransactionQuery()
+	query.UserID = &userID
+	lastEvent := changeCursor(0, "previous")
+
+	var streamed int
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Watch(ctx, WatchRequest{Query: query, After: lastEvent}, func(changes []*Change) error {
+			streamed += len(changes)
+			if streamed > watchBatchSize {
+				// the client goes away once the last change is streamed
+				cancel()
+			}
+			return nil
+		})
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(watchPollInterval / 2):
+		t.Fatal("expected the changes after a full batch to be read at once, without waiting for the next poll")
+	}
+
+	if streamed != watchBatchSize+1 || len(repo.after) != 2 {
+		t.Fatalf("expected %d changes in 2 lookups, got %d in %d", watchBatchSize+1, streamed, len(repo.after))
+	}
+	if repo.after[0] != lastEvent || repo.after[1] != full[len(full)-1].Cursor {
+		t.Errorf("expected the lookups to resume after the last event then after the last change, got %v", repo.after)
+	}
+}
+
+func TestWatchRefusesAForeignCursor(t *testing.T) {
+	s := newTestService(t, &changesRepository{})
+	userID := "u1"
+	query := NewTransactionQuery()
+	query.UserID = &userID
+
+	// the cursor of a page of the listing, sorted by creation date
+	page := &Cursor{Sort: DefaultSort().String(), Values: []interface{}{int64(1), "t1"}}
+	err := s.Watch(context.Background(), WatchRequest{Query: query, After: page}, func([]*Change) error { return nil })
+	if statusCode(err) != http.StatusBadRequest {
+		t.Errorf("expected the cursor of a listing to be refused, got %v", err)
+	}
+}
+
+func TestGetByIDHidesTheUnreadableTransactionsOfOtherUsers(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"owned by the user", unreadableError("u1"), 0},
+		{"owned by another user", unreadableError("u2"), http.StatusNotFound},
+		{"unknown owner", unreadableError(""), http.StatusNotFound},
+		{"missing", errors.NewNotFoundError("transaction"), http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, &unreadableRepository{err: tt.err})
+			_, err := s.GetByID(context.Background(), TransactionRequest{UserID: "u1", TransactionID: "t1"})
+			if err == nil || statusCode(err) != tt.status {
+				t.Errorf("expected the status %d, got %v", tt.status, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package transactions
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+)
+
+// sortableFields is the whitelist of the fields a client can sort transactions on
+var sortableFields = map[string]bool{
+	"creation_date": true,
+	"due_date":      true,
+	"amount":        true,
+	"currency":      true,
+	"status":        true,
+	"type":          true,
+}
+
+// SortField is one criterion of a sort specification
+type SortField struct {
+	Field     string `json:"field"`
+	Ascending bool   `json:"ascending"`
+}
+
+// SortSpec is an ordered list of sort criteria, the first one having the highest priority
+type SortSpec []SortField
+
+// DefaultSort returns the sort applied when the client does not ask for one: newest transactions first
+func DefaultSort() SortSpec {
+	return SortSpec{{Field: "creation_date", Ascending: false}}
+}
+
+// ParseSort decodes a specification like `amount:desc,creation_date:asc`. The direction defaults to asc and
+// the legacy `asc` and `desc` values are still accepted as a sort on creation_date.
+func ParseSort(spec string) (SortSpec, error) {
+	switch spec {
+	case "asc":
+		return SortSpec{{Field: "creation_date", Ascending: true}}, nil
+	case "desc":
+		return DefaultSort(), nil
+	}
+
+	var sort SortSpec
+	seen := map[string]bool{}
+	for _, criterion := range strings.Split(spec, ",") {
+		parts := strings.Split(strings.TrimSpace(criterion), ":")
+		if len(parts) > 2 || parts[0] == "" {
+			return nil, errors.NewInvalidArgument(fmt.Sprintf("invalid parameter 'sort': malformed criterion '%s'", criterion))
+		}
+
+		field := parts[0]
+		if !sortableFields[field] {
+			return nil, errors.NewInvalidArgument(fmt.Sprintf("invalid parameter 'sort': '%s' is not sortable", field))
+		}
+		if seen[field] {
+			return nil, errors.NewInvalidArgument(fmt.Sprintf("invalid parameter 'sort': '%s' is repeated", field))
+		}
+		seen[field] = true
+
+		ascending := true
+		if len(parts) == 2 {
+			switch parts[1] {
+			case "asc":
+			case "desc":
+				ascending = false
+			default:
+				return nil, errors.NewInvalidArgument(fmt.Sprintf("invalid parameter 'sort': unknown direction '%s'", parts[1]))
+			}
+		}
+
+		sort = append(sort, SortField{Field: field, Ascending: ascending})
+	}
+	return sort, nil
+}
+
+// String encodes the specification back in the format accepted by ParseSort
+func (s SortSpec) String() string {
+	criteria := make([]string, len(s))
+	for i, field := range s {
+		direction := "desc"
+		if field.Ascending {
+			direction = "asc"
+		}
+		criteria[i] = field.Field + ":" + direction
+	}
+	return strings.Join(criteria, ",")
+}
@@ -0,0 +1,95 @@
+package transactions
+
+import (
+	"math"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+)
+
+// StatementIssuer identifies the institution issuing the statements
+type StatementIssuer struct {
+	BankID string
+	BIC    string
+	Name   string
+}
+
+// Statement holds what a bank statement reports on top of the transactions: the account and its balances
+// at the boundaries of the period
+type Statement struct {
+	Issuer         StatementIssuer `json:"-"`
+	AccountID      string          `json:"account_id"`
+	Currency       string          `json:"currency"`
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	OpeningBalance float64         `json:"opening_balance"`
+	ClosingBalance float64         `json:"closing_balance"`
+}
+
+// balanceDirection tells how each type of transaction moves the balance of the user: credits and refunds add
+// money, fees and payments take it. Invoices, receipts and other documents do not move money.
+var balanceDirection = map[string]float64{
+	TypeCredit:  1,
+	TypeRefund:  1,
+	TypeFee:     -1,
+	TypePayment: -1,
+}
+
+// SignedAmount returns the amount of a transaction as seen on a statement, negative when money goes out.
+// Amounts of documents which do not move money are returned as stored.
+func SignedAmount(t *Transaction) float64 {
+	if direction, ok := balanceDirection[t.Type]; ok {
+		return direction * math.Abs(t.Amount)
+	}
+	return t.Amount
+}
+
+// IsMovement tells whether the transaction moves money on the account of the user, and thus appears on statements
+// listing bookings only
+func IsMovement(t *Transaction) bool {
+	_, ok := balanceDirection[t.Type]
+	return ok
+}
+
+// IsBooked tells whether the transaction is an entry of a statement: it moves money and was not cancelled. The
+// balances of a statement are computed from the same transactions, so that its entries add up to them.
+func IsBooked(t *Transaction) bool {
+	return IsMovement(t) && t.Status != StatusCancelled
+}
+
+// bookedStatuses restricts the statuses of a statement query to the ones of booked transactions. An empty result
+// means no transaction can be booked.
+func bookedStatuses(statuses []Status) []Status {
+	booked := []Status{}
+	for _, status := range statuses {
+		if status != StatusCancelled {
+			booked = append(booked, status)
+		}
+	}
+	return booked
+}
+
+// validateStatement checks that a statement can be produced for the query: it needs a user, a start date and a
+// single currency as balances can not be added up across currencies
+func validateStatement(query TransactionQuery) error {
+	if query.UserID == nil {
+		return errors.NewInvalidArgument("statements are only available for a user")
+	}
+	if query.DateFrom == nil {
+		return errors.NewInvalidArgument("statements need a `date_from`")
+	}
+	if len(query.Currency) != 1 {
+		return errors.NewInvalidArgument("statements need a single 'currency'")
+	}
+	return nil
+}
+
+// balanceAt computes the balance of the transactions of a summary by type. The amounts of the summary must have
+// the same sign within a type, so that their sum is signed like the ones of its transactions, see SignedAmount.
+func balanceAt(summary *TransactionsSummary, currency string) float64 {
+	balance := 0.0
+	for _, bucket := range summary.ByType {
+		balance += balanceDirection[bucket.Key] * bucket.Amounts[currency]
+	}
+	return balance
+}
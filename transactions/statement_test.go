@@ -0,0 +1,194 @@
+package transactions
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ledgerRepository answers the balance queries of a statement from stored transactions, recording them. It
+// applies the filters set by the statements: type, status, excluded status, amount and creation date.
+type ledgerRepository struct {
+	Repository
+	stored  []*Transaction
+	queries []TransactionQuery
+}
+
+func (r *ledgerRepository) matches(query TransactionQuery, t *Transaction) bool {
+	if len(query.Type) > 0 && !containsString(query.Type, t.Type) {
+		return false
+	}
+	if len(query.Status) > 0 && !containsStatus(query.Status, t.Status) || containsStatus(query.ExcludedStatus, t.Status) {
+		return false
+	}
+	if query.AmountMin != nil && t.Amount < *query.AmountMin || query.AmountMax != nil && t.Amount > *query.AmountMax {
+		return false
+	}
+	return query.DateTo == nil || t.CreationDate.Before(*query.DateTo)
+}
+
+func (r *ledgerRepository) GetSummaryByUser(ctx context.Context, request SummaryRequest) (*TransactionsSummary, error) {
+	r.queries = append(r.queries, request.Query)
+	summary := &TransactionsSummary{}
+	byType := map[string]*SummaryBucket{}
+	for _, t := range r.stored {
+		if !r.matches(request.Query, t) {
+			continue
+		}
+		bucket, ok := byType[t.Type]
+		if !ok {
+			bucket = &SummaryBucket{Key: t.Type, Amounts: map[string]float64{}}
+			byType[t.Type] = bucket
+			summary.ByType = append(summary.ByType, bucket)
+		}
+		bucket.Count++
+		bucket.Amounts[t.Currency] += t.Amount
+	}
+	return summary, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsStatus(values []Status, value Status) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestGetStatementBalancesUseTheStatementFilters(t *testing.T) {
+	before := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+	repo := &ledgerRepository{stored: []*Transaction{
+		{Type: TypeCredit, Status: StatusPaid, Amount: 100, Currency: "EUR", CreationDate: before},
+		{Type: TypeFee, Status: StatusPaid, Amount: 30, Currency: "EUR", CreationDate: before},
+		{Type: TypeInvoice, Status: StatusPaid, Amount: 1000, Currency: "EUR", CreationDate: before},
+		{Type: TypeCredit, Status: StatusCancelled, Amount: 40, Currency: "EUR", CreationDate: before},
+	}}
+	s, err := NewService(repo, StatementIssuer{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userID := "u1"
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	query := NewTransactionQuery()
+	query.UserID = &userID
+	query.Currency = []string{"EUR"}
+	query.DateFrom, query.DateTo = &from, &to
+	query.Type = []string{TypeCredit, TypeFee}
+	query.Status = []Status{StatusPaid, StatusCancelled}
+
+	statement, err := s.GetStatement(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statement.OpeningBalance != 70 || statement.ClosingBalance != 70 {
+		t.Errorf("balances %v/%v, want 70 (invoices do not move money)", statement.OpeningBalance, statement.ClosingBalance)
+	}
+
+	for _, q := range repo.queries {
+		if q.DateFrom != nil || q.DateTo == nil || !(q.DateTo.Equal(from) || q.DateTo.Equal(to)) {
+			t.Errorf("balance covers %v - %v, want everything before a boundary of the statement", q.DateFrom, q.DateTo)
+		}
+		if !reflect.DeepEqual(q.Type, query.Type) {
+			t.Errorf("balance types %v, want the statement ones %v", q.Type, query.Type)
+		}
+		if !reflect.DeepEqual(q.Status, []Status{StatusPaid}) {
+			t.Errorf("balance statuses %v, want the statement ones without cancelled", q.Status)
+		}
+	}
+}
+
+// TestGetStatementBalancesAddUpTheEntries checks that the balances add up the transactions the statement lists
+// as entries: the ones without status are kept, cancelled ones are not, and each amount counts whatever its sign
+func TestGetStatementBalancesAddUpTheEntries(t *testing.T) {
+	at := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	stored := []*Transaction{
+		{Type: TypeCredit, Status: StatusPaid, Amount: 100, Currency: "EUR", CreationDate: at},
+		{Type: TypeCredit, Status: StatusPaid, Amount: -20, Currency: "EUR", CreationDate: at},
+		{Type: TypeCredit, Amount: 5, Currency: "EUR", CreationDate: at},
+		{Type: TypeCredit, Status: StatusCancelled, Amount: 50, Currency: "EUR", CreationDate: at},
+		{Type: TypeFee, Status: StatusOpen, Amount: 10, Currency: "EUR", CreationDate: at},
+		{Type: TypeFee, Status: StatusPaid, Amount: -4, Currency: "EUR", CreationDate: at},
+		{Type: TypeInvoice, Status: StatusOpen, Amount: 300, Currency: "EUR", CreationDate: at},
+	}
+	repo := &ledgerRepository{stored: stored}
+	s, _ := NewService(repo, StatementIssuer{}, 1)
+
+	userID := "u1"
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	query := NewTransactionQuery()
+	query.UserID = &userID
+	query.Currency = []string{"EUR"}
+	query.DateFrom, query.DateTo = &from, &to
+
+	statement, err := s.GetStatement(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := 0.0
+	for _, transaction := range stored {
+		if IsBooked(transaction) {
+			entries += SignedAmount(transaction)
+		}
+	}
+	if entries != 111 {
+		t.Fatalf("entries add up to %v, want 111", entries)
+	}
+	if statement.OpeningBalance != 0 || statement.ClosingBalance != entries {
+		t.Errorf("balances %v/%v, want 0/%v", statement.OpeningBalance, statement.ClosingBalance, entries)
+	}
+	for _, q := range repo.queries {
+		if len(q.Status) != 0 || !reflect.DeepEqual(q.ExcludedStatus, []Status{StatusCancelled}) {
+			t.Errorf("balance query statuses %v excluding %v, want only cancelled excluded", q.Status, q.ExcludedStatus)
+		}
+	}
+}
+
+func TestStatementWritersOnlyExportBookedTransactions(t *testing.T) {
+	statement := &Statement{AccountID: "u1", Currency: "EUR", From: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)}
+	at := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	list := []*Transaction{
+		{ID: "fee-1", Type: TypeFee, Status: StatusPaid, Amount: 12, Currency: "EUR", CreationDate: at},
+		{ID: "invoice-1", Type: TypeInvoice, Status: StatusOpen, Amount: 500, Currency: "EUR", CreationDate: at},
+		{ID: "credit-1", Type: TypeCredit, Status: StatusCancelled, Amount: 40, Currency: "EUR", CreationDate: at},
+	}
+
+	for _, format := range []ExportFormat{ExportOFX, ExportQIF} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewExportWriter(&buf, ExportRequest{Format: format}, statement)
+			if err := w.Write(list); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			out := buf.String()
+			if !strings.Contains(out, "-12.00") {
+				t.Errorf("the paid fee is missing:\n%s", out)
+			}
+			for _, unexpected := range []string{"invoice-1", "500.00", "credit-1", "40.00", "OTHER"} {
+				if strings.Contains(out, unexpected) {
+					t.Errorf("unexpected %q in the statement:\n%s", unexpected, out)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package transactions
+
+// Status is the payment status of a transaction
+type Status string
+
+// All the payment statuses a transaction can go through
+const (
+	StatusOpen          Status = "open"
+	StatusPartiallyPaid Status = "partially_paid"
+	StatusPaid          Status = "paid"
+	StatusCancelled     Status = "cancelled"
+	StatusRefunded      Status = "refunded"
+)
+
+// OpenStatuses are the statuses of transactions which still have an amount due, they back the `open=true` alias
+var OpenStatuses = []Status{StatusOpen, StatusPartiallyPaid}
+
+// SettledStatuses are the statuses of transactions with nothing left to pay, they back the `open=false` alias
+var SettledStatuses = []Status{StatusPaid, StatusCancelled, StatusRefunded}
+
+// IsValid tells whether the status is one of the known statuses
+func (s Status) IsValid() bool {
+	switch s {
+	case StatusOpen, StatusPartiallyPaid, StatusPaid, StatusCancelled, StatusRefunded:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package transactions
+
+import (
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+)
+
+// Interval is the width of the buckets of a summary histogram
+type Interval string
+
+// All the accepted histogram intervals
+const (
+	IntervalDay   Interval = "day"
+	IntervalWeek  Interval = "week"
+	IntervalMonth Interval = "month"
+)
+
+// SummaryRequest asks for the aggregated figures of the transactions matching Query
+type SummaryRequest struct {
+	Query    TransactionQuery `json:"query"`
+	Interval Interval         `json:"interval"`
+}
+
+// Validate checks the query and the interval
+func (r SummaryRequest) Validate() error {
+	switch r.Interval {
+	case IntervalDay, IntervalWeek, IntervalMonth:
+	default:
+		return errors.NewInvalidArgument("invalid parameter 'interval'")
+	}
+	return r.Query.Validate()
+}
+
+// TransactionsSummary holds counts and amounts of transactions, in total and split by type, by status and over time.
+// Amounts are summed per currency as they can not be added up across currencies.
+type TransactionsSummary struct {
+	Count     int64              `json:"count"`
+	Amounts   map[string]float64 `json:"amounts"`
+	ByType    []*SummaryBucket   `json:"by_type"`
+	ByStatus  []*SummaryBucket   `json:"by_status"`
+	Histogram []*HistogramBucket `json:"histogram"`
+}
+
+// SummaryBucket aggregates the transactions sharing the same Key
+type SummaryBucket struct {
+	Key     string             `json:"key"`
+	Count   int64              `json:"count"`
+	Amounts map[string]float64 `json:"amounts"`
+}
+
+// HistogramBucket aggregates the transactions created in the interval starting at Date
+type HistogramBucket struct {
+	Date    time.Time          `json:"date"`
+	Count   int64              `json:"count"`
+	Amounts map[string]float64 `json:"amounts"`
+}
@@ -0,0 +1,53 @@
+package transactions
+
+import (
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+)
+
+const (
+	// watchPollInterval is the delay between two lookups for changes
+	watchPollInterval = 2 * time.Second
+	// watchSettleDelay is how long a change waits before being streamed, so that the writes stamped before it have
+	// become searchable and are not skipped by the cursor
+	watchSettleDelay = 5 * time.Second
+	// watchBatchSize is the number of changes read at once
+	watchBatchSize = 100
+)
+
+// ChangesSort returns the order of the changes, by ascending update date, ties being broken by the repository.
+// Repository.GetChanges sorts by it, so that its cursors are accepted by WatchRequest.
+func ChangesSort() SortSpec {
+	return SortSpec{{Field: "updated_at", Ascending: true}}
+}
+
+// Change is a new or updated transaction, with the cursor to resume right after it
+type Change struct {
+	Transaction *Transaction
+	Cursor      *Cursor
+}
+
+// WatchRequest asks for the changes of the transactions matching Query, after the change of the cursor After, or
+// from now when After is nil. Sort and pagination of the query are ignored.
+type WatchRequest struct {
+	Query TransactionQuery `json:"query"`
+	After *Cursor          `json:"after"`
+}
+
+// Validate checks the query and that the cursor comes from a change
+func (r WatchRequest) Validate() error {
+	if r.Query.UserID == nil {
+		return errors.NewInvalidArgument("invalid parameter 'user_id'")
+	}
+	changesSort := ChangesSort()
+	if r.After != nil && (r.After.Sort != changesSort.String() || len(r.After.Values) != len(changesSort)+1) {
+		return errors.NewInvalidArgument("invalid header 'Last-Event-ID'")
+	}
+	return r.Query.Validate()
+}
+
+// startCursor resumes from now, the empty document ID sorting before any other
+func startCursor(now time.Time) *Cursor {
+	return &Cursor{Sort: ChangesSort().String(), Values: []interface{}{now.Add(-watchSettleDelay).UnixNano() / int64(time.Millisecond), ""}}
+}
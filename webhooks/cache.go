@@ -0,0 +1,81 @@
+package webhooks
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxCachedUsers bounds the number of users whose subscriptions are cached
+const maxCachedUsers = 10000
+
+type cachedSubscriptions struct {
+	subscriptions []*Subscription
+	expiration    time.Time
+}
+
+// cachingRepository keeps the subscriptions of the users in memory for ttl, so that the transactions written
+// do not each cost a lookup. The subscriptions of a user are read again once one of them is created or deleted
+// through this repository, the changes made by other instances are seen after ttl at most.
+type cachingRepository struct {
+	Repository
+	ttl time.Duration
+
+	mu    sync.Mutex
+	users map[string]cachedSubscriptions
+}
+
+// NewCachingRepository decorates the repository with a cache of the subscriptions by user
+func NewCachingRepository(repo Repository, ttl time.Duration) Repository {
+	return &cachingRepository{Repository: repo, ttl: ttl, users: make(map[string]cachedSubscriptions)}
+}
+
+// GetSubscriptionsByUser returns the cached subscriptions, which must not be modified
+func (r *cachingRepository) GetSubscriptionsByUser(ctx context.Context, userID string) ([]*Subscription, error) {
+	now := time.Now()
+	r.mu.Lock()
+	cached, ok := r.users[userID]
+	r.mu.Unlock()
+	if ok && now.Before(cached.expiration) {
+		return cached.subscriptions, nil
+	}
+
+	subscriptions, err := r.Repository.GetSubscriptionsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.users) >= maxCachedUsers {
+		for id, cached := range r.users {
+			if !now.Before(cached.expiration) {
+				delete(r.users, id)
+			}
+		}
+		if len(r.users) >= maxCachedUsers {
+			r.users = make(map[string]cachedSubscriptions)
+		}
+	}
+	r.users[userID] = cachedSubscriptions{subscriptions: subscriptions, expiration: now.Add(r.ttl)}
+	return subscriptions, nil
+}
+
+func (r *cachingRepository) CreateSubscription(ctx context.Context, subscription *Subscription) error {
+	defer r.forget(subscription.UserID)
+	return r.Repository.CreateSubscription(ctx, subscription)
+}
+
+func (r *cachingRepository) DeleteSubscription(ctx context.Context, subscriptionID string) error {
+	subscription, err := r.Repository.GetSubscription(ctx, subscriptionID)
+	if err == nil {
+		defer r.forget(subscription.UserID)
+	}
+	return r.Repository.DeleteSubscription(ctx, subscriptionID)
+}
+
+func (r *cachingRepository) forget(userID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.users, userID)
+}
@@ -0,0 +1,122 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// blockedNetworks are the addresses a webhook can not be delivered to: the loopback, private, link-local and
+// otherwise internal networks, which would let a user reach the services next to the API
+var blockedNetworks = parseNetworks(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/3",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
+
+// isPublicIP tells whether a webhook can be delivered to the address, see blockedNetworks
+func isPublicIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// isLocalHostname tells whether the name designates the host itself, whatever it resolves to
+func isLocalHostname(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	return host == "localhost" || strings.HasSuffix(host, ".localhost")
+}
+
+// checkHost refuses the hosts which are not public, resolving the names with lookup
+func checkHost(ctx context.Context, host string, lookup func(ctx context.Context, host string) ([]net.IPAddr, error)) error {
+	if isLocalHostname(host) {
+		return fmt.Errorf("host '%s' is not public", host)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if !isPublicIP(ip) {
+			return fmt.Errorf("address %s is not public", ip)
+		}
+		return nil
+	}
+
+	addresses, err := lookup(ctx, host)
+	if err != nil {
+		return fmt.Errorf("host '%s' can not be resolved", host)
+	}
+	for _, address := range addresses {
+		if !isPublicIP(address.IP) {
+			return fmt.Errorf("host '%s' resolves to %s, which is not public", host, address.IP)
+		}
+	}
+	return nil
+}
+
+// NewClient returns the client the deliveries are posted with. Its connections are only opened to public
+// addresses, checked once the host is resolved so that a name resolving to another address than at the creation
+// of the webhook is refused too. Proxies are not used, and redirects are not followed so that a delivery only
+// reaches the registered URL.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublicIP(net.ParseIP(host)) {
+				return fmt.Errorf("address %s is not public", host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConnsPerHost: 4,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,82 @@
+package webhooks
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubscriptionRequestValidate(t *testing.T) {
+	tests := []struct {
+		url   string
+		types []string
+		valid bool
+	}{
+		{"https://hooks.example.com/transactions", nil, true},
+		{"https://93.184.216.34:8443/hook", []string{"invoice", "refund"}, true},
+		{"https://hooks.example.com/transactions", []string{"transfer"}, false},
+		{"ftp://hooks.example.com/transactions", nil, false},
+		{"http://localhost:8080/hook", nil, false},
+		{"http://api.localhost/hook", nil, false},
+		{"http://127.0.0.1/hook", nil, false},
+		{"http://10.1.2.3/hook", nil, false},
+		{"http://172.20.0.1/hook", nil, false},
+		{"http://192.168.1.1/hook", nil, false},
+		{"http://169.254.169.254/latest/meta-data", nil, false},
+		{"http://0.0.0.0/hook", nil, false},
+		{"http://[::1]/hook", nil, false},
+		{"http://[fe80::1]/hook", nil, false},
+		{"http://[fd00::1]/hook", nil, false},
+		{"http://[::ffff:127.0.0.1]/hook", nil, false},
+	}
+	for _, test := range tests {
+		request := SubscriptionRequest{URL: test.url, Events: []EventType{TransactionPaid}, Types: test.types}
+		if err := request.Validate(); (err == nil) != test.valid {
+			t.Errorf("%s %v: expected valid=%t, got %v", test.url, test.types, test.valid, err)
+		}
+	}
+}
+
+func TestCheckHostResolvesNames(t *testing.T) {
+	lookup := func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		switch host {
+		case "public.example.com":
+			return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}}, nil
+		case "internal.example.com":
+			return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}, {IP: net.ParseIP("10.0.0.7")}}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host}
+	}
+
+	if err := checkHost(context.Background(), "public.example.com", lookup); err != nil {
+		t.Errorf("expected a public host to be accepted, got %v", err)
+	}
+	if err := checkHost(context.Background(), "internal.example.com", lookup); err == nil {
+		t.Error("expected a host resolving to a private address to be refused")
+	}
+	if err := checkHost(context.Background(), "unknown.example.com", lookup); err == nil {
+		t.Error("expected a host which can not be resolved to be refused")
+	}
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	reached := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer server.Close()
+
+	// the name is resolved to the loopback address of the test server
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	_, err := NewClient(time.Second).Get(url)
+	if err == nil || !strings.Contains(err.Error(), "not public") {
+		t.Errorf("expected the connection to be refused, got %v", err)
+	}
+	if reached {
+		t.Error("expected the server not to be reached")
+	}
+}
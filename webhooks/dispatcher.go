@@ -0,0 +1,249 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/logger"
+	"github.com/fsilberstein/parameters-issue/transactions"
+	"go.uber.org/zap"
+)
+
+const (
+	// queueSize is the number of events waiting to be matched against the subscriptions before Publish drops
+	// them, and the number of deliveries waiting for a worker
+	queueSize = 1024
+	// maxBackoff caps the delay between two attempts
+	maxBackoff = time.Hour
+	// logTimeout bounds the writing of an entry of the delivery log
+	logTimeout = 5 * time.Second
+	// lookupTimeout bounds the reading of the subscriptions of a user
+	lookupTimeout = 5 * time.Second
+)
+
+// ErrQueueFull is returned by Publish when the event can not be queued, it is then dropped
+var ErrQueueFull = stderrors.New("webhooks queue is full")
+
+type job struct {
+	subscription *Subscription
+	event        *Event
+	payload      []byte
+	attempt      int
+}
+
+// Dispatcher posts the events to the matching subscriptions from a pool of workers. Publish only queues the
+// event, it is matched against the subscriptions in the background so that the writes of transactions never wait
+// for the webhooks. A failed delivery, a response which is not a 2xx, is retried after an exponential backoff
+// until maxAttempts attempts were made. Each attempt is written to the delivery log. Events and deliveries are
+// kept in memory: those pending when the process stops are lost.
+type Dispatcher struct {
+	repo           Repository
+	client         *http.Client
+	maxAttempts    int
+	initialBackoff time.Duration
+
+	events chan *Event
+	jobs   chan *job
+	done   chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+// NewDispatcher starts the workers
+func NewDispatcher(repo Repository, client *http.Client, workers, maxAttempts int, initialBackoff time.Duration) *Dispatcher {
+	d := &Dispatcher{
+		repo:           repo,
+		client:         client,
+		maxAttempts:    maxAttempts,
+		initialBackoff: initialBackoff,
+		events:         make(chan *Event, queueSize),
+		jobs:           make(chan *job, queueSize),
+		done:           make(chan struct{}),
+	}
+
+	d.wg.Add(1)
+	go d.route()
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+	return d
+}
+
+// Close stops the workers once their current delivery is done, pending retries are dropped
+func (d *Dispatcher) Close() {
+	d.once.Do(func() {
+		close(d.done)
+	})
+	d.wg.Wait()
+}
+
+// Publish queues the event without waiting, it returns ErrQueueFull when the queue is full
+func (d *Dispatcher) Publish(ctx context.Context, eventType EventType, transaction *transactions.Transaction) error {
+	id, err := randomID(16)
+	if err != nil {
+		return err
+	}
+	// the event is marshalled later, the transaction may have changed by then
+	snapshot := *transaction
+	event := &Event{ID: id, Type: eventType, CreationDate: time.Now(), Transaction: &snapshot}
+
+	select {
+	case <-d.done:
+		return fmt.Errorf("webhooks dispatcher is closed")
+	default:
+	}
+	select {
+	case d.events <- event:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// route queues the deliveries of the events to the subscriptions they match
+func (d *Dispatcher) route() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.done:
+			return
+		case event := <-d.events:
+			if err := d.dispatch(event); err != nil {
+				logger.LogStdErr.Error("webhook event dropped", zap.String("event", event.ID), zap.String("transaction", event.Transaction.ID), zap.Error(err))
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(event *Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	subscriptions, err := d.repo.GetSubscriptionsByUser(ctx, event.Transaction.UserID)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	var payload []byte
+	for _, subscription := range subscriptions {
+		if !subscription.Matches(event) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = json.Marshal(event); err != nil {
+				return err
+			}
+		}
+		if err := d.enqueue(context.Background(), &job{subscription: subscription, event: event, payload: payload}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) enqueue(ctx context.Context, j *job) error {
+	select {
+	case d.jobs <- j:
+		return nil
+	case <-d.done:
+		return fmt.Errorf("webhooks dispatcher is closed")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.done:
+			return
+		case j := <-d.jobs:
+			d.deliver(j)
+		}
+	}
+}
+
+// backoff returns the delay before the attempt following the given one
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.initialBackoff
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+func (d *Dispatcher) deliver(j *job) {
+	j.attempt++
+	start := time.Now()
+	statusCode, err := d.post(j, start)
+
+	delivery := &Delivery{
+		SubscriptionID: j.subscription.ID,
+		EventID:        j.event.ID,
+		EventType:      j.event.Type,
+		Attempt:        j.attempt,
+		Result:         DeliverySucceeded,
+		StatusCode:     statusCode,
+		Duration:       time.Since(start),
+		Date:           start,
+	}
+	if err == nil && (statusCode < 200 || statusCode > 299) {
+		err = fmt.Errorf("unexpected status %d", statusCode)
+	}
+	if err != nil {
+		delivery.Result = DeliveryFailed
+		delivery.Error = err.Error()
+		if j.attempt < d.maxAttempts {
+			delay := d.backoff(j.attempt)
+			next := start.Add(delay)
+			delivery.Result = DeliveryRetrying
+			delivery.NextAttempt = &next
+			time.AfterFunc(delay, func() {
+				if err := d.enqueue(context.Background(), j); err != nil {
+					logger.LogStdErr.Error("webhook retry dropped", zap.String("subscription", j.subscription.ID), zap.Error(err))
+				}
+			})
+		}
+	}
+
+	if id, err := randomID(16); err == nil {
+		delivery.ID = id
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), logTimeout)
+	defer cancel()
+	if err := d.repo.LogDelivery(ctx, delivery); err != nil {
+		logger.LogStdErr.Error("could not log the webhook delivery", zap.String("subscription", j.subscription.ID), zap.Error(err))
+	}
+}
+
+// post sends the signed payload, the body of the response is drained for the connection to be reused
+func (d *Dispatcher) post(j *job, now time.Time) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, j.subscription.URL, bytes.NewReader(j.payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set(SignatureHeader, Sign(j.subscription.Secret, now, j.payload))
+	req.Header.Set(EventHeader, string(j.event.Type))
+	req.Header.Set(DeliveryHeader, j.event.ID)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
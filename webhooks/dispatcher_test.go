@@ -0,0 +1,121 @@
+package webhooks
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+// memoryRepository keeps the subscriptions and the delivery log, counting the lookups of subscriptions
+type memoryRepository struct {
+	Repository
+	mu            sync.Mutex
+	subscriptions []*Subscription
+	lookups       int
+	deliveries    []*Delivery
+	// blocked, when set, holds the lookups until it is closed
+	blocked chan struct{}
+}
+
+func (r *memoryRepository) GetSubscriptionsByUser(ctx context.Context, userID string) ([]*Subscription, error) {
+	if r.blocked != nil {
+		<-r.blocked
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups++
+	var result []*Subscription
+	for _, subscription := range r.subscriptions {
+		if subscription.UserID == userID {
+			result = append(result, subscription)
+		}
+	}
+	return result, nil
+}
+
+func (r *memoryRepository) GetSubscription(ctx context.Context, subscriptionID string) (*Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, subscription := range r.subscriptions {
+		if subscription.ID == subscriptionID {
+			return subscription, nil
+		}
+	}
+	return nil, errors.NewNotFoundError("webhook")
+}
+
+func (r *memoryRepository) CreateSubscription(ctx context.Context, subscription *Subscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscriptions = append(r.subscriptions, subscription)
+	return nil
+}
+
+func (r *memoryRepository) LogDelivery(ctx context.Context, delivery *Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deliveries = append(r.deliveries, delivery)
+	return nil
+}
+
+func (r *memoryRepository) getDeliveries() []*Delivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Delivery(nil), r.deliveries...)
+}
+
+func TestPublishNeverBlocks(t *testing.T) {
+	repo := &memoryRepository{blocked: make(chan struct{})}
+	dispatcher := NewDispatcher(repo, nil, 1, 1, time.Millisecond)
+	defer dispatcher.Close()
+	defer close(repo.blocked)
+
+	transaction := &transactions.Transaction{ID: "t1", UserID: "u1"}
+	done := make(chan error)
+	go func() {
+		// the router holds an event, the queue takes queueSize more
+		var err error
+		for i := 0; i <= queueSize+1 && err == nil; i++ {
+			err = dispatcher.Publish(context.Background(), TransactionCreated, transaction)
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != ErrQueueFull {
+			t.Errorf("expected the events to be dropped once the queue is full, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publish blocked on a full queue")
+	}
+}
+
+func TestCachingRepositoryLooksUpOncePerUser(t *testing.T) {
+	repo := &memoryRepository{subscriptions: []*Subscription{{ID: "s1", UserID: "u1"}}}
+	cache := NewCachingRepository(repo, time.Hour)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		subscriptions, err := cache.GetSubscriptionsByUser(ctx, "u1")
+		if err != nil || len(subscriptions) != 1 {
+			t.Fatalf("expected the subscription of the user, got %v %v", subscriptions, err)
+		}
+	}
+	if repo.lookups != 1 {
+		t.Errorf("expected a single lookup, got %d", repo.lookups)
+	}
+
+	// a new subscription is seen at once
+	if err := cache.CreateSubscription(ctx, &Subscription{ID: "s2", UserID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	subscriptions, _ := cache.GetSubscriptionsByUser(ctx, "u1")
+	if len(subscriptions) != 2 || repo.lookups != 2 {
+		t.Errorf("expected the subscriptions to be read again after a creation, got %d in %d lookups", len(subscriptions), repo.lookups)
+	}
+}
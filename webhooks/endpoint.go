@@ -0,0 +1,52 @@
+package webhooks
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// Endpoints represents all endpoints
+type Endpoints struct {
+	CreateEndpoint        endpoint.Endpoint
+	GetByUserEndpoint     endpoint.Endpoint
+	DeleteEndpoint        endpoint.Endpoint
+	GetDeliveriesEndpoint endpoint.Endpoint
+}
+
+func MakeEndpoints(s Service) Endpoints {
+	return Endpoints{
+		CreateEndpoint:        makeCreateEndpoint(s),
+		GetByUserEndpoint:     makeGetByUserEndpoint(s),
+		DeleteEndpoint:        makeDeleteEndpoint(s),
+		GetDeliveriesEndpoint: makeGetDeliveriesEndpoint(s),
+	}
+}
+
+func makeCreateEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SubscriptionRequest)
+		return s.Create(ctx, req)
+	}
+}
+
+func makeGetByUserEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		userID := request.(string)
+		return s.GetByUser(ctx, userID)
+	}
+}
+
+func makeDeleteEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SubscriptionID)
+		return nil, s.Delete(ctx, req)
+	}
+}
+
+func makeGetDeliveriesEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SubscriptionID)
+		return s.GetDeliveries(ctx, req)
+	}
+}
@@ -0,0 +1,137 @@
+package webhooks
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+// EventType is the kind of change a webhook is notified of
+type EventType string
+
+// All the event types a webhook can subscribe to
+const (
+	TransactionCreated EventType = "transaction.created"
+	TransactionUpdated EventType = "transaction.updated"
+	TransactionPaid    EventType = "transaction.paid"
+)
+
+var eventTypes = map[EventType]bool{
+	TransactionCreated: true,
+	TransactionUpdated: true,
+	TransactionPaid:    true,
+}
+
+// maxSubscriptionsByUser bounds the number of webhooks of a user
+const maxSubscriptionsByUser = 20
+
+// Subscription is a webhook: the events of the user matching the filters are posted to URL, signed with Secret.
+// The secret is only returned when the subscription is created.
+type Subscription struct {
+	ID           string      `json:"id"`
+	UserID       string      `json:"user_id"`
+	URL          string      `json:"url"`
+	Events       []EventType `json:"events"`
+	Types        []string    `json:"types,omitempty"`
+	Secret       string      `json:"secret,omitempty"`
+	CreationDate time.Time   `json:"creation_date"`
+}
+
+// Matches tells whether the event must be delivered to the subscription, an empty list of types matching any
+// transaction type. For example, a paid invoice is {events: [transaction.paid], types: [invoice]} and an issued
+// refund {events: [transaction.created], types: [refund]}.
+func (s *Subscription) Matches(event *Event) bool {
+	if event.Transaction.UserID != s.UserID {
+		return false
+	}
+
+	matches := false
+	for _, eventType := range s.Events {
+		matches = matches || eventType == event.Type
+	}
+	if !matches || len(s.Types) == 0 {
+		return matches
+	}
+
+	for _, t := range s.Types {
+		if t == event.Transaction.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// SubscriptionRequest asks for the creation of a webhook
+type SubscriptionRequest struct {
+	UserID string      `json:"-"`
+	URL    string      `json:"url"`
+	Events []EventType `json:"events"`
+	Types  []string    `json:"types"`
+}
+
+// Validate checks the URL and the filters. A URL to the host itself or to an address which is not public is
+// refused, a name being only resolved by the service.
+func (r SubscriptionRequest) Validate() error {
+	u, err := url.Parse(r.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.NewInvalidArgument("field 'url' must be an absolute http or https URL")
+	}
+	host := u.Hostname()
+	if ip := net.ParseIP(host); isLocalHostname(host) || (ip != nil && !isPublicIP(ip)) {
+		return errors.NewInvalidArgument("field 'url' must target a public address")
+	}
+	if len(r.Events) == 0 {
+		return errors.NewInvalidArgument("field 'events' must hold at least an event type")
+	}
+	for _, eventType := range r.Events {
+		if !eventTypes[eventType] {
+			return errors.NewInvalidArgument(fmt.Sprintf("field 'events': unknown event type '%s'", eventType))
+		}
+	}
+	for _, transactionType := range r.Types {
+		if !transactions.IsTypeValid(transactionType) {
+			return errors.NewInvalidArgument(fmt.Sprintf("field 'types': unknown transaction type '%s'", transactionType))
+		}
+	}
+	return nil
+}
+
+// SubscriptionID identifies a webhook of a user
+type SubscriptionID struct {
+	UserID         string `json:"user_id"`
+	SubscriptionID string `json:"subscription_id"`
+}
+
+// Event is a change of a transaction, posted to the matching webhooks as its JSON representation
+type Event struct {
+	ID           string                    `json:"id"`
+	Type         EventType                 `json:"type"`
+	CreationDate time.Time                 `json:"creation_date"`
+	Transaction  *transactions.Transaction `json:"data"`
+}
+
+// Results of a delivery attempt
+const (
+	DeliverySucceeded = "succeeded"
+	DeliveryRetrying  = "retrying"
+	DeliveryFailed    = "failed"
+)
+
+// Delivery is an entry of the delivery log, one per attempt to post an event to a webhook
+type Delivery struct {
+	ID             string        `json:"id"`
+	SubscriptionID string        `json:"subscription_id"`
+	EventID        string        `json:"event_id"`
+	EventType      EventType     `json:"event_type"`
+	Attempt        int           `json:"attempt"`
+	Result         string        `json:"result"`
+	StatusCode     int           `json:"status_code,omitempty"`
+	Error          string        `json:"error,omitempty"`
+	Duration       time.Duration `json:"duration"`
+	Date           time.Time     `json:"date"`
+	NextAttempt    *time.Time    `json:"next_attempt,omitempty"`
+}
@@ -0,0 +1,133 @@
+package webhooks
+
+import (
+	"context"
+
+	"github.com/fsilberstein/parameters-issue/logger"
+	"github.com/fsilberstein/parameters-issue/transactions"
+	"go.uber.org/zap"
+)
+
+// Publisher is told about the changes of transactions
+type Publisher interface {
+	Publish(ctx context.Context, eventType EventType, transaction *transactions.Transaction) error
+}
+
+// notifyingRepository publishes the writes of the decorated repository. A failure to publish is logged, it does
+// not fail the write.
+type notifyingRepository struct {
+	transactions.Repository
+	publisher Publisher
+}
+
+// NewNotifyingRepository decorates the repository so that every created or updated transaction is published.
+// A transaction written with the paid status while it was not paid is published as paid, a transaction created
+// paid being published as created then as paid. A patch of the annotations is published as an update.
+func NewNotifyingRepository(repo transactions.Repository, publisher Publisher) transactions.Repository {
+	return &notifyingRepository{Repository: repo, publisher: publisher}
+}
+
+func (r *notifyingRepository) publish(ctx context.Context, eventType EventType, transaction *transactions.Transaction) {
+	if err := r.publisher.Publish(ctx, eventType, transaction); err != nil {
+		logger.LogStdErr.Error("could not publish the transaction", zap.String("transaction", transaction.ID), zap.String("event", string(eventType)), zap.Error(err))
+	}
+}
+
+// publishWrite publishes the transaction written over the previous state, nil for a creation
+func (r *notifyingRepository) publishWrite(ctx context.Context, previous, transaction *transactions.Transaction) {
+	paid := transaction.Status == transactions.StatusPaid
+	switch {
+	case previous == nil:
+		r.publish(ctx, TransactionCreated, transaction)
+		if paid {
+			r.publish(ctx, TransactionPaid, transaction)
+		}
+	case paid && previous.Status != transactions.StatusPaid:
+		r.publish(ctx, TransactionPaid, transaction)
+	default:
+		r.publish(ctx, TransactionUpdated, transaction)
+	}
+}
+
+func (r *notifyingRepository) Create(ctx context.Context, transaction *transactions.Transaction) error {
+	if err := r.Repository.Create(ctx, transaction); err != nil {
+		return err
+	}
+	r.publishWrite(ctx, nil, transaction)
+	return nil
+}
+
+func (r *notifyingRepository) Save(ctx context.Context, transaction *transactions.Transaction) error {
+	// the previous state tells a creation from an update and whether the transaction became paid
+	previous, _ := r.Repository.GetByID(ctx, transaction.ID)
+
+	if err := r.Repository.Save(ctx, transaction); err != nil {
+		return err
+	}
+	r.publishWrite(ctx, previous, transaction)
+	return nil
+}
+
+func (r *notifyingRepository) Bulk(ctx context.Context, list []*transactions.Transaction) ([]*transactions.BulkItemResult, error) {
+	previous := r.getPrevious(ctx, list)
+
+	results, err := r.Repository.Bulk(ctx, list)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, result := range results {
+		switch result.Result {
+		case transactions.BulkCreated:
+			list[i].ID = result.ID
+			r.publishWrite(ctx, nil, list[i])
+		case transactions.BulkUpdated:
+			p := previous[list[i].ID]
+			if p == nil {
+				// the previous state is unknown, the transaction is not told as paid
+				p = list[i]
+			}
+			r.publishWrite(ctx, p, list[i])
+		}
+	}
+	return results, nil
+}
+
+// getPrevious reads the stored transactions the list has the ID of. They are read in realtime, a transaction
+// written just before being known too. A failure is logged, the transactions are then published as updated.
+func (r *notifyingRepository) getPrevious(ctx context.Context, list []*transactions.Transaction) map[string]*transactions.Transaction {
+	var ids []string
+	for _, transaction := range list {
+		if transaction.ID != "" {
+			ids = append(ids, transaction.ID)
+		}
+	}
+
+	previous := make(map[string]*transactions.Transaction)
+	if len(ids) == 0 {
+		return previous
+	}
+	stored, err := r.Repository.GetByIDs(ctx, ids)
+	if err != nil {
+		logger.LogStdErr.Error("could not read the transactions before the bulk write", zap.Error(err))
+		return previous
+	}
+	for _, transaction := range stored {
+		previous[transaction.ID] = transaction
+	}
+	return previous
+}
+
+func (r *notifyingRepository) Update(ctx context.Context, transactionID string, patch transactions.TransactionPatch, version int64) error {
+	if err := r.Repository.Update(ctx, transactionID, patch, version); err != nil {
+		return err
+	}
+
+	transaction, err := r.Repository.GetByID(ctx, transactionID)
+	if err != nil {
+		logger.LogStdErr.Error("could not read the updated transaction", zap.String("transaction", transactionID), zap.Error(err))
+		return nil
+	}
+	r.publish(ctx, TransactionUpdated, transaction)
+	return nil
+}
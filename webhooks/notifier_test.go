@@ -0,0 +1,84 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+// recordingPublisher records the published events as `<type> <transaction ID>`
+type recordingPublisher struct {
+	events []string
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, eventType EventType, transaction *transactions.Transaction) error {
+	p.events = append(p.events, fmt.Sprintf("%s %s", eventType, transaction.ID))
+	return nil
+}
+
+// storedRepository writes nothing, it only knows the stored transactions
+type storedRepository struct {
+	transactions.Repository
+	stored map[string]*transactions.Transaction
+}
+
+func (r *storedRepository) Create(ctx context.Context, transaction *transactions.Transaction) error {
+	return nil
+}
+
+func (r *storedRepository) GetByIDs(ctx context.Context, ids []string) ([]*transactions.Transaction, error) {
+	var result []*transactions.Transaction
+	for _, id := range ids {
+		if transaction, ok := r.stored[id]; ok {
+			result = append(result, transaction)
+		}
+	}
+	return result, nil
+}
+
+func (r *storedRepository) Bulk(ctx context.Context, list []*transactions.Transaction) ([]*transactions.BulkItemResult, error) {
+	results := make([]*transactions.BulkItemResult, len(list))
+	for i, transaction := range list {
+		results[i] = &transactions.BulkItemResult{ID: transaction.ID, Result: transactions.BulkUpdated}
+		if _, ok := r.stored[transaction.ID]; !ok {
+			results[i].Result = transactions.BulkCreated
+		}
+	}
+	return results, nil
+}
+
+func TestNotifierPublishesPaidTransactions(t *testing.T) {
+	publisher := &recordingPublisher{}
+	repo := NewNotifyingRepository(&storedRepository{stored: map[string]*transactions.Transaction{
+		"open": {ID: "open", UserID: "u1", Status: transactions.StatusOpen},
+		"paid": {ID: "paid", UserID: "u1", Status: transactions.StatusPaid},
+	}}, publisher)
+	ctx := context.Background()
+
+	if err := repo.Create(ctx, &transactions.Transaction{ID: "created", UserID: "u1", Status: transactions.StatusPaid}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := repo.Bulk(ctx, []*transactions.Transaction{
+		{ID: "new", UserID: "u1", Status: transactions.StatusPaid},
+		{ID: "open", UserID: "u1", Status: transactions.StatusPaid},
+		{ID: "paid", UserID: "u1", Status: transactions.StatusPaid},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"transaction.created created",
+		"transaction.paid created",
+		"transaction.created new",
+		"transaction.paid new",
+		"transaction.paid open",
+		"transaction.updated paid",
+	}
+	if strings.Join(publisher.events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected the events\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(publisher.events, "\n"))
+	}
+}
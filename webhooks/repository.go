@@ -0,0 +1,15 @@
+package webhooks
+
+import "context"
+
+// Repository persists the subscriptions and the delivery log
+type Repository interface {
+	CreateSubscription(ctx context.Context, subscription *Subscription) error
+	GetSubscription(ctx context.Context, subscriptionID string) (*Subscription, error)
+	// GetSubscriptionsByUser returns the subscriptions of the user, secrets included
+	GetSubscriptionsByUser(ctx context.Context, userID string) ([]*Subscription, error)
+	DeleteSubscription(ctx context.Context, subscriptionID string) error
+	LogDelivery(ctx context.Context, delivery *Delivery) error
+	// GetDeliveries returns the last deliveries of the subscription, most recent first
+	GetDeliveries(ctx context.Context, subscriptionID string, size int) ([]*Delivery, error)
+}
@@ -0,0 +1,121 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/errors"
+)
+
+// maxDeliveries is the number of entries of the delivery log returned
+const maxDeliveries = 100
+
+// Service is the webhook service interface
+type Service interface {
+	Create(ctx context.Context, request SubscriptionRequest) (*Subscription, error)
+	GetByUser(ctx context.Context, userID string) ([]*Subscription, error)
+	Delete(ctx context.Context, id SubscriptionID) error
+	GetDeliveries(ctx context.Context, id SubscriptionID) ([]*Delivery, error)
+}
+
+type service struct {
+	repo Repository
+	// lookupIP resolves the host of the webhooks
+	lookupIP func(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// NewService initializes new service
+func NewService(repo Repository) (Service, error) {
+	return &service{
+		repo:     repo,
+		lookupIP: net.DefaultResolver.LookupIPAddr,
+	}, nil
+}
+
+// Create stores the subscription with a new secret, which is only returned now. The host of the URL must resolve
+// to public addresses, the client of the deliveries checking them again when it connects.
+func (s *service) Create(ctx context.Context, request SubscriptionRequest) (*Subscription, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	u, _ := url.Parse(request.URL)
+	if err := checkHost(ctx, u.Hostname(), s.lookupIP); err != nil {
+		return nil, errors.NewInvalidArgument(fmt.Sprintf("field 'url': %s", err))
+	}
+
+	existing, err := s.repo.GetSubscriptionsByUser(ctx, request.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxSubscriptionsByUser {
+		return nil, errors.NewInvalidArgument(fmt.Sprintf("a user can not have more than %d webhooks", maxSubscriptionsByUser))
+	}
+
+	id, err := randomID(16)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomID(32)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription := &Subscription{
+		ID:           id,
+		UserID:       request.UserID,
+		URL:          request.URL,
+		Events:       request.Events,
+		Types:        request.Types,
+		Secret:       secret,
+		CreationDate: time.Now(),
+	}
+	if err := s.repo.CreateSubscription(ctx, subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// GetByUser returns the subscriptions of the user without their secrets
+func (s *service) GetByUser(ctx context.Context, userID string) ([]*Subscription, error) {
+	subscriptions, err := s.repo.GetSubscriptionsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Subscription, len(subscriptions))
+	for i, subscription := range subscriptions {
+		copy := *subscription
+		copy.Secret = ""
+		result[i] = &copy
+	}
+	return result, nil
+}
+
+// get returns the subscription, a subscription of another user being reported as not found
+func (s *service) get(ctx context.Context, id SubscriptionID) (*Subscription, error) {
+	subscription, err := s.repo.GetSubscription(ctx, id.SubscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.UserID != id.UserID {
+		return nil, errors.NewNotFoundError("webhook")
+	}
+	return subscription, nil
+}
+
+func (s *service) Delete(ctx context.Context, id SubscriptionID) error {
+	if _, err := s.get(ctx, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteSubscription(ctx, id.SubscriptionID)
+}
+
+func (s *service) GetDeliveries(ctx context.Context, id SubscriptionID) ([]*Delivery, error) {
+	if _, err := s.get(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetDeliveries(ctx, id.SubscriptionID, maxDeliveries)
+}
@@ -0,0 +1,72 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of a delivery. The signature header is `t=<unix timestamp>,v1=<hex HMAC-SHA256>`, the HMAC being
+// computed with the secret of the subscription over `<unix timestamp>.<body>`.
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// Sign returns the signature header of the body sent at the given time
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", t, computeHMAC(secret, t, body))
+}
+
+func computeHMAC(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks a signature header against the body, for receivers to authenticate the deliveries.
+// Signatures made more than tolerance before or after now are refused, to prevent replays.
+func VerifySignature(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			signature = kv[1]
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || signature == "" {
+		return fmt.Errorf("malformed signature header")
+	}
+	if diff := now.Sub(time.Unix(unix, 0)); diff > tolerance || diff < -tolerance {
+		return fmt.Errorf("signature timestamp is out of tolerance")
+	}
+	if !hmac.Equal([]byte(signature), []byte(computeHMAC(secret, timestamp, body))) {
+		return fmt.Errorf("signature does not match")
+	}
+	return nil
+}
+
+// randomID returns a random hexadecimal identifier of n bytes
+func randomID(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
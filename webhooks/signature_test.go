@@ -0,0 +1,102 @@
+package webhooks
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsilberstein/parameters-issue/transactions"
+)
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"e1"}`)
+	header := Sign("secret", now, body)
+
+	tests := []struct {
+		name   string
+		secret string
+		body   string
+		at     time.Time
+		valid  bool
+	}{
+		{"same time", "secret", `{"id":"e1"}`, now, true},
+		{"within tolerance", "secret", `{"id":"e1"}`, now.Add(4 * time.Minute), true},
+		{"too old", "secret", `{"id":"e1"}`, now.Add(6 * time.Minute), false},
+		{"in the future", "secret", `{"id":"e1"}`, now.Add(-6 * time.Minute), false},
+		{"other secret", "other", `{"id":"e1"}`, now, false},
+		{"other body", "secret", `{"id":"e2"}`, now, false},
+	}
+	for _, test := range tests {
+		err := VerifySignature(test.secret, header, []byte(test.body), test.at, 5*time.Minute)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: expected valid=%t, got %v", test.name, test.valid, err)
+		}
+	}
+	if err := VerifySignature("secret", "v1=abc", body, now, time.Minute); err == nil {
+		t.Error("expected a header without timestamp to be refused")
+	}
+}
+
+func TestDispatcherDeliversSignedEventsAndRetries(t *testing.T) {
+	var mu sync.Mutex
+	var attempts []string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := VerifySignature("secret", r.Header.Get(SignatureHeader), body, time.Now(), time.Minute); err != nil {
+			t.Errorf("expected a valid signature, got %v", err)
+		}
+		if r.Header.Get(EventHeader) != string(TransactionPaid) {
+			t.Errorf("expected the event type header, got %s", r.Header.Get(EventHeader))
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		attempts = append(attempts, r.Header.Get(DeliveryHeader))
+		// the first attempt fails
+		if len(attempts) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer receiver.Close()
+
+	repo := &memoryRepository{subscriptions: []*Subscription{
+		{ID: "s1", UserID: "u1", URL: receiver.URL, Events: []EventType{TransactionPaid}, Secret: "secret"},
+		{ID: "s2", UserID: "u1", URL: receiver.URL, Events: []EventType{TransactionCreated}, Secret: "secret"},
+	}}
+	dispatcher := NewDispatcher(repo, receiver.Client(), 2, 3, 10*time.Millisecond)
+	defer dispatcher.Close()
+
+	transaction := &transactions.Transaction{ID: "t1", UserID: "u1", Type: transactions.TypeInvoice, Status: transactions.StatusPaid}
+	if err := dispatcher.Publish(context.Background(), TransactionPaid, transaction); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	var deliveries []*Delivery
+	for len(deliveries) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		deliveries = repo.getDeliveries()
+	}
+	if len(deliveries) != 2 {
+		t.Fatalf("expected a failed then a successful attempt, got %d deliveries", len(deliveries))
+	}
+
+	first, second := deliveries[0], deliveries[1]
+	if first.Result != DeliveryRetrying || first.StatusCode != http.StatusInternalServerError || first.NextAttempt == nil {
+		t.Errorf("expected the first attempt to be retried, got %+v", first)
+	}
+	if second.Result != DeliverySucceeded || second.Attempt != 2 || second.SubscriptionID != "s1" {
+		t.Errorf("expected the second attempt to succeed, got %+v", second)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(attempts) != 2 || attempts[0] != attempts[1] {
+		t.Errorf("expected the same event to be posted twice to the paid subscription, got %v", attempts)
+	}
+}